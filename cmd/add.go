@@ -0,0 +1,154 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// sources for the password of add and edit commands
+const (
+	passwordSourcePrompt   = "prompt"
+	passwordSourceStdin    = "stdin"
+	passwordSourceGenerate = "generate"
+)
+
+// add flags storage
+type AddFlags struct {
+	Login          string
+	PasswordSource string
+	Group          string
+}
+
+// add flags storage
+var addFlags = AddFlags{}
+
+// addCmd represents the add command
+var addCmd = &cobra.Command{
+	Use:   "add",
+	Args:  cobra.NoArgs,
+	Run:   addCmdRun,
+	Short: "Create a new entry",
+	Long: fmt.Sprintf(`Create a new entry.
+
+The entry is created with the URL "%s"
+(or the one from config key "%s"), so it is found by the other commands.
+Keepassxc names the new entry after that URL, it can be renamed in keepassxc afterwards.
+The group of the entry is given by its full path, e.g. "/scripts/clip", see "%s groups -h".
+
+The password is read from the source given by flag:
+  %s: ask for it twice on the terminal (default)
+  %s: read it from stdin, a trailing newline is removed
+  %s: let keepassxc generate it, see "%s generate -h"`,
+		utils.ConfigDefaultScriptIndicatorUrl,
+		utils.ConfigKeypathScriptIndicatorUrl,
+		utils.ApplicationNameShort,
+		passwordSourcePrompt,
+		passwordSourceStdin,
+		passwordSourceGenerate,
+		utils.ApplicationNameShort,
+	),
+	Example: fmt.Sprintf("  %s add ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"-l myname", "-l myname -g /scripts/clip", "-l myname -s generate", "-l myname -s stdin <secret.txt"},
+		fmt.Sprintf("\n  %s add ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+	addCmd.Flags().StringVarP(&addFlags.Login, "login", "l", "",
+		"The login (user name) of the new entry.")
+	addCmd.Flags().StringVarP(&addFlags.PasswordSource, "password-source", "s", passwordSourcePrompt,
+		fmt.Sprintf("Where to get the password from: %s, %s or %s.",
+			passwordSourcePrompt, passwordSourceStdin, passwordSourceGenerate))
+	addCmd.Flags().StringVarP(&addFlags.Group, "group", "g", "",
+		"The full path of the group to create the entry in (default keepassxc's default group).")
+	addCmd.MarkFlagRequired("login")
+}
+
+func addCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
+	defer client.Disconnect()
+
+	login := keepassxc.LoginData{
+		Url:   viper.GetString(utils.ConfigKeypathScriptIndicatorUrl),
+		Login: addFlags.Login,
+	}
+	if addFlags.Group != "" {
+		group, err := findGroup(ctx, client, addFlags.Group)
+		checkErr(err)
+		login.Group, login.GroupUuid = group.Name, group.Uuid
+	}
+
+	login.Password, err = readPassword(client, addFlags.PasswordSource)
+	checkErr(err)
+
+	ctx, cancel = keepassxcContext()
+	defer cancel()
+	checkErr(client.SetLoginContext(ctx, login))
+	fmt.Printf("Created entry for %s\n", addFlags.Login)
+}
+
+// findGroup returns the group with the given full path from the group tree of the database.
+// Keepassxc only creates the entry in a group, if both its name and UUID are sent, see keepassxc.LoginData.
+func findGroup(ctx context.Context, client *keepassxc.Client, path string) (*keepassxc.Group, error) {
+	groups, err := client.GetDatabaseGroupsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path = "/" + strings.Trim(path, "/")
+	if group := groups.FindByPath(path); group != nil {
+		return group, nil
+	}
+	return nil, errors.Join(fmt.Errorf("group %s not found", path), utils.ErrKeepassxcGroupNotFound)
+}
+
+// readPassword gets a password from the given source, see passwordSource* constants.
+func readPassword(client *keepassxc.Client, source string) (keepassxc.Password, error) {
+	switch source {
+	case passwordSourcePrompt:
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		repeated, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(password, repeated) {
+			return "", fmt.Errorf("Passwords do not match")
+		}
+		return keepassxc.Password(password), nil
+	case passwordSourceStdin:
+		password, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return keepassxc.Password(strings.TrimSuffix(strings.TrimSuffix(string(password), "\n"), "\r")), nil
+	case passwordSourceGenerate:
+		ctx, cancel := keepassxcContext()
+		defer cancel()
+		return client.GeneratePasswordContext(ctx)
+	default:
+		return "", fmt.Errorf("Unknown password source: %s", source)
+	}
+}
@@ -4,6 +4,8 @@ Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"keepassxc-http-tools-go/pkg/keepassxc"
 	"keepassxc-http-tools-go/pkg/utils"
@@ -37,7 +39,9 @@ var clipCmd = &cobra.Command{
 
 The entries from keepassxc which match the URL "%s"
 (or the one from config key "%s") are scanned by this command.
-The entries are optionally filtered by the group names given at config key "%s".
+The entries are optionally filtered by the groups given at config key "%s".
+Those are either group names or full group paths like "/scripts/clip",
+which may contain patterns like "/scripts/**" or "/work/*/prod".
 Finally if any "namefilters" arguments are given, the entries will be reduced to only those,
 which contain all the namefilters as substring in their entry names.
 If at this point multiple entries still match all the criteria,
@@ -76,40 +80,12 @@ func init() {
 }
 
 func clipCmdRun(cmd *cobra.Command, args []string) {
-	// get entries from keepassxc
-	client, err := keepassxc.NewClient(utils.ViperKeepassxcProfile{})
-	cobra.CheckErr(err)
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
 	defer client.Disconnect()
-	scriptIndicatorUrl := viper.GetString(utils.ConfigKeypathScriptIndicatorUrl)
-	entries, err := client.GetLogins(scriptIndicatorUrl)
-	cobra.CheckErr(err)
-
-	// filter entries by configured groups
-	groups := viper.GetStringSlice(utils.ConfigKeypathClipFilterGroups)
-	if len(groups) > 0 {
-		entries = entries.FilterByGroup(groups...)
-	}
-
-	// filter entries by optional name filter arguments
-	filter := scriptIndicatorUrl
-	if len(args) > 0 {
-		filter = strings.Join(args, " ")
-		entries = entries.FilterByName(args...)
-	}
-	var selectedEntry *keepassxc.Entry
-	switch len(entries) {
-	case 0:
-		cobra.CheckErr(fmt.Errorf("No logins match the search criteria: %s", filter))
-	case 1:
-		selectedEntry = entries[0]
-	default:
-		// and if multiple are left, chose one per fuzzy finder
-		idx, err := fzf.Find(entries, func(i int) string {
-			return entries[i].GetCombined(viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier))
-		})
-		cobra.CheckErr(err)
-		selectedEntry = entries[idx]
-	}
+	selectedEntry := selectEntry(ctx, client, viper.GetStringSlice(utils.ConfigKeypathClipFilterGroups), args)
 
 	// select the value(s) to copy from the selected entry, either from flag
 	var copyKeys []string
@@ -133,13 +109,69 @@ func clipCmdRun(cmd *cobra.Command, args []string) {
 	copyValue := selectedEntry.GetCombined(copyKeys)
 
 	// copy that value to clipboard
-	err = clip.Init()
-	cobra.CheckErr(err)
-	clip.Write(clip.FmtText, []byte(copyValue))
-	// it seems we need at least some (~5?) milliseconds to be sure the value is copied into clipboard
-	time.Sleep(100 * time.Millisecond)
+	copyToClipboard(copyValue)
 
 	fmt.Printf("Copied %s from %s\n",
 		utils.GetCombinedKeys(copyKeys),
 		selectedEntry.GetCombined(viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier)))
 }
+
+// copyToClipboard writes the value as text to the clipboard.
+func copyToClipboard(value string) {
+	err := clip.Init()
+	checkErr(err)
+	clip.Write(clip.FmtText, []byte(value))
+	// it seems we need at least some (~5?) milliseconds to be sure the value is copied into clipboard
+	time.Sleep(100 * time.Millisecond)
+}
+
+// selectEntry gets the entries for the script indicator URL from keepassxc and reduces them to a single one.
+// The entries are filtered by the given groups (if any) and name filters (if any),
+// if multiple entries are left, one is chosen by fuzzy finder.
+func selectEntry(ctx context.Context, client *keepassxc.Client, groups, nameFilters []string) *keepassxc.Entry {
+	entries := findEntries(ctx, client, groups, nameFilters)
+	if len(entries) == 1 {
+		return entries[0]
+	}
+	// and if multiple are left, chose one per fuzzy finder
+	idx, err := fzf.Find(entries, func(i int) string {
+		return entries[i].GetCombined(viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier))
+	})
+	checkErr(err)
+	return entries[idx]
+}
+
+// findEntries gets the entries for the script indicator URL from keepassxc.
+// The entries are filtered by the given groups (if any) and name filters (if any),
+// it fails if no entries are left.
+func findEntries(ctx context.Context, client *keepassxc.Client, groups, nameFilters []string) keepassxc.Entries {
+	// get entries from keepassxc
+	scriptIndicatorUrl := viper.GetString(utils.ConfigKeypathScriptIndicatorUrl)
+	entries, err := client.GetLoginsContext(ctx, scriptIndicatorUrl)
+	checkErr(err)
+
+	// filter entries by configured groups, full paths need the group tree of the database
+	if len(groups) > 0 {
+		for _, g := range groups {
+			if strings.HasPrefix(g, "/") {
+				dbGroups, err := client.GetDatabaseGroupsContext(ctx)
+				checkErr(err)
+				entries.ResolveGroupPaths(dbGroups)
+				break
+			}
+		}
+		entries = entries.FilterByGroup(groups...)
+	}
+
+	// filter entries by optional name filter arguments
+	filter := scriptIndicatorUrl
+	if len(nameFilters) > 0 {
+		filter = strings.Join(nameFilters, " ")
+		entries = entries.FilterByName(nameFilters...)
+	}
+	if len(entries) == 0 {
+		checkErr(errors.Join(fmt.Errorf("No logins match the search criteria: %s", filter),
+			utils.ErrKeepassxcNoLoginsFound))
+	}
+	return entries
+}
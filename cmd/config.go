@@ -22,7 +22,7 @@ var configCmd = &cobra.Command{
 	
 The default config file is %s.
 Most of the config is optional, see the example config from this command for details.
-The "assoc" profile will be created and saved automatically on first connection to the database.`,
+The "assocs" profiles will be created and saved automatically on first connection to each database.`,
 		path.Join(utils.GetConfigDir(), utils.ConfigFileNameDefault),
 	),
 	Example: fmt.Sprintf("  %s config", utils.ApplicationNameShort),
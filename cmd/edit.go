@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// edit flags storage
+type EditFlags struct {
+	Login          string
+	PasswordSource string
+}
+
+// edit flags storage
+var editFlags = EditFlags{}
+
+// editCmd represents the edit command
+var editCmd = &cobra.Command{
+	Use:   "edit [namefilters...]",
+	Args:  cobra.ArbitraryArgs,
+	Run:   editCmdRun,
+	Short: "Update login and password of an entry",
+	Long: fmt.Sprintf(`Update login and password of an entry.
+
+The entry is selected the same way as for the clip command, see "%s clip -h".
+The login is kept, unless a new one is given by flag.
+The password is read from the source given by flag, see "%s add -h" for details.
+Keepassxc may ask to confirm the update.`,
+		utils.ApplicationNameShort,
+		utils.ApplicationNameShort,
+	),
+	Example: fmt.Sprintf("  %s edit ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"", "myentry -s generate", "myentry -l newname"},
+		fmt.Sprintf("\n  %s edit ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+	editCmd.Flags().StringVarP(&editFlags.Login, "login", "l", "",
+		"The new login (user name) of the entry (default keep the current login).")
+	editCmd.Flags().StringVarP(&editFlags.PasswordSource, "password-source", "s", passwordSourcePrompt,
+		fmt.Sprintf("Where to get the password from: %s, %s or %s.",
+			passwordSourcePrompt, passwordSourceStdin, passwordSourceGenerate))
+}
+
+func editCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
+	defer client.Disconnect()
+	selectedEntry := selectEntry(ctx, client, viper.GetStringSlice(utils.ConfigKeypathClipFilterGroups), args)
+
+	login := selectedEntry.Login
+	if editFlags.Login != "" {
+		login = editFlags.Login
+	}
+	password, err := readPassword(client, editFlags.PasswordSource)
+	checkErr(err)
+
+	ctx, cancel = keepassxcContext()
+	defer cancel()
+	err = client.SetLoginContext(ctx, keepassxc.LoginData{
+		Url:      viper.GetString(utils.ConfigKeypathScriptIndicatorUrl),
+		Login:    login,
+		Password: password,
+		Uuid:     selectedEntry.Uuid,
+	})
+	checkErr(err)
+	fmt.Printf("Updated %s\n", selectedEntry.GetCombined(viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier)))
+}
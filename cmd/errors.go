@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepassxc-http-tools-go/pkg/utils"
+	"os"
+)
+
+// exit codes by error category
+const (
+	// any error without a specific category
+	exitCodeGeneric = 1
+	// keepassxc is not reachable
+	exitCodeConnection = 2
+	// the database is locked or not opened
+	exitCodeDatabaseLocked = 3
+	// the user cancelled or denied the action in keepassxc
+	exitCodeDenied = 4
+	// the association of the config is missing or invalid
+	exitCodeAssociation = 5
+	// no entries match the search criteria
+	exitCodeNoLogins = 6
+	// the time limit of the --timeout flag was exceeded
+	exitCodeTimeout = 7
+)
+
+// errorCategory assigns an exit code and a hint for the user to errors.
+type errorCategory struct {
+	exitCode int
+	hint     string
+	errs     []error
+}
+
+// errorCategories are checked in order, the first category with any matching error wins.
+var errorCategories = []errorCategory{
+	{
+		exitCode: exitCodeTimeout,
+		hint:     "Keepassxc did not answer in time, maybe it waits for a confirmation. Increase the --timeout flag.",
+		errs:     []error{context.DeadlineExceeded},
+	},
+	{
+		exitCode: exitCodeDatabaseLocked,
+		hint:     "Unlock the database in keepassxc, or use the --wait-unlock flag to wait for it.",
+		errs:     []error{utils.ErrKeepassxcDatabaseNotOpened, utils.ErrKeepassxcNoSavedDatabasesFound},
+	},
+	{
+		exitCode: exitCodeDenied,
+		hint:     "The action was cancelled or denied in keepassxc, allow it there.",
+		errs:     []error{utils.ErrKeepassxcActionCancelledOrDenied, utils.ErrKeepassxcAccessToAllEntriesDenied},
+	},
+	{
+		exitCode: exitCodeAssociation,
+		hint: fmt.Sprintf("The association with the database is not valid. "+
+			"Remove it from the config keys \"%s\" and \"%s\" to associate again.",
+			utils.ConfigKeypathAssocs, utils.ConfigKeypathAssoc),
+		errs: []error{utils.ErrKeepassxcAssocFailed, utils.ErrKeepassxcTestAssocFailed,
+			utils.ErrKeepassxcEncryptionKeyUnrecognized},
+	},
+	{
+		exitCode: exitCodeAssociation,
+		hint: fmt.Sprintf("The opened database is not pinned in the config key \"%s\" and the config key \"%s\" "+
+			"refuses other databases. Open the right database in keepassxc.",
+			utils.ConfigKeypathPinnedDatabases, utils.ConfigKeypathDatabasePinning),
+		errs: []error{utils.ErrKeepassxcDatabaseNotPinned},
+	},
+	{
+		exitCode: exitCodeNoLogins,
+		hint: fmt.Sprintf("Check that the entries have the URL from config key \"%s\" and match the filters.",
+			utils.ConfigKeypathScriptIndicatorUrl),
+		errs: []error{utils.ErrKeepassxcNoLoginsFound},
+	},
+	{
+		exitCode: exitCodeGeneric,
+		hint:     "Set up the totp of the entry in keepassxc.",
+		errs:     []error{utils.ErrKeepassxcTotpNotFound},
+	},
+	{
+		exitCode: exitCodeGeneric,
+		hint: fmt.Sprintf("Check the group path, \"%s groups\" prints the group tree and creates groups.",
+			utils.ApplicationNameShort),
+		errs: []error{utils.ErrKeepassxcGroupNotFound},
+	},
+	{
+		exitCode: exitCodeConnection,
+		hint: fmt.Sprintf("The socket is not served by keepassxc of the current user, someone may impersonate it. "+
+			"Check the owner of the socket and the config key \"%s\".", utils.ConfigKeypathPeerExecutable),
+		errs: []error{utils.ErrKeepassxcPeerVerificationFailed},
+	},
+	{
+		exitCode: exitCodeConnection,
+		hint: fmt.Sprintf("Check that keepassxc is running and browser integration is enabled in its settings. "+
+			"Set $%s to the socket path, if it is not found.", utils.EnvSocketPath),
+		errs: []error{utils.ErrKeepassxcSocketNotFound, utils.ErrKeepassxcConnectFailed, utils.ErrKeepassxcConnectionClosed,
+			utils.ErrKeepassxcReconnectFailed, utils.ErrKeepassxcTimeoutOrNotConnected},
+	},
+}
+
+// checkErr prints the error with a hint and exits with the exit code of its category, if err is not nil.
+// It replaces cobra.CheckErr() for errors that may come from keepassxc.
+func checkErr(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	for _, category := range errorCategories {
+		for _, target := range category.errs {
+			if errors.Is(err, target) {
+				fmt.Fprintln(os.Stderr, "Hint:", category.hint)
+				os.Exit(category.exitCode)
+			}
+		}
+	}
+	os.Exit(exitCodeGeneric)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"fmt"
+	"keepassxc-http-tools-go/pkg/utils"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// generate flags storage
+type GenerateFlags struct {
+	Clip bool
+}
+
+// generate flags storage
+var generateFlags = GenerateFlags{}
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Args:  cobra.NoArgs,
+	Run:   generateCmdRun,
+	Short: "Generate a password with keepassxc's password generator",
+	Long: `Generate a password with keepassxc's password generator.
+
+The password is generated by keepassxc according to the password generator settings configured there,
+so it follows the same policy as passwords generated in the GUI.
+Newer keepassxc versions show the password generator dialog, the password is returned after it is accepted there.
+The password is printed to stdout, unless it should be copied to clipboard.`,
+	Example: fmt.Sprintf("  %s generate ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"", "-C"},
+		fmt.Sprintf("\n  %s generate ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+	generateCmd.Flags().BoolVarP(&generateFlags.Clip, "clip", "C", false,
+		"Copy the password to clipboard instead of printing it.")
+}
+
+func generateCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
+	defer client.Disconnect()
+	password, err := client.GeneratePasswordContext(ctx)
+	checkErr(err)
+
+	if !generateFlags.Clip {
+		fmt.Println(password.Plaintext())
+		return
+	}
+	copyToClipboard(password.Plaintext())
+	fmt.Println("Copied generated password")
+}
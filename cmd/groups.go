@@ -0,0 +1,83 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// groups flags storage
+type GroupsFlags struct {
+	Create   []string
+	ShowUuid bool
+}
+
+// groups flags storage
+var groupsFlags = GroupsFlags{}
+
+// groupsCmd represents the groups command
+var groupsCmd = &cobra.Command{
+	Use:   "groups",
+	Args:  cobra.NoArgs,
+	Run:   groupsCmdRun,
+	Short: "Print the group tree of the database and create groups",
+	Long: `Print the group tree of the database and create groups.
+
+Groups are identified by their full path inside the database, e.g. "/scripts/clip".
+The root group has the path "/".
+Groups to create are given by their full path, missing parent groups are created as well.
+Existing groups are left untouched.`,
+	Example: fmt.Sprintf("  %s groups ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"", "-u", "-n /scripts/clip", "-n /scripts/clip -n /scripts/vpn"},
+		fmt.Sprintf("\n  %s groups ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(groupsCmd)
+	groupsCmd.Flags().StringArrayVarP(&groupsFlags.Create, "create", "n", nil,
+		"Create the group with this full path, may be given multiple times.")
+	groupsCmd.Flags().BoolVarP(&groupsFlags.ShowUuid, "uuid", "u", false,
+		"Print the UUIDs of the groups as well.")
+}
+
+func groupsCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
+	defer client.Disconnect()
+
+	if len(groupsFlags.Create) > 0 {
+		for _, path := range groupsFlags.Create {
+			group, err := client.CreateNewGroupContext(ctx, path)
+			checkErr(err)
+			fmt.Printf("Created group %s (%s)\n", group.Path, group.Uuid)
+		}
+		return
+	}
+
+	groups, err := client.GetDatabaseGroupsContext(ctx)
+	checkErr(err)
+	printGroups(groups, 0)
+}
+
+// printGroups prints the group tree indented by depth, the root group by its path.
+func printGroups(groups keepassxc.Groups, depth int) {
+	for _, group := range groups {
+		name := group.Name
+		if depth == 0 {
+			name = group.Path
+		}
+		if groupsFlags.ShowUuid {
+			name = fmt.Sprintf("%s (%s)", name, group.Uuid)
+		}
+		fmt.Printf("%s%s\n", strings.Repeat("  ", depth), name)
+		printGroups(group.Children, depth+1)
+	}
+}
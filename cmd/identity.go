@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// identity flags storage
+type IdentityFlags struct {
+	// generate a new client identity
+	Rotate bool
+}
+
+// identity flags storage
+var identityFlags = IdentityFlags{}
+
+// identityCmd represents the identity command
+var identityCmd = &cobra.Command{
+	Use:   "identity",
+	Args:  cobra.NoArgs,
+	Run:   identityCmdRun,
+	Short: "Print or rotate the client identity",
+	Long: fmt.Sprintf(`Print or rotate the client identity.
+
+The client id and key pair are saved in the config on first connection and reused afterwards,
+the client id identifies this tool in the keepassxc logs.
+The rotation replaces them by new ones, the associations stay valid.
+The config contains the private key, so it is saved readable by the user only.
+The identity is stored at the config key path "%s".`, utils.ConfigKeypathClient),
+	Example: fmt.Sprintf("  %s identity ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"", "-r"},
+		fmt.Sprintf("\n  %s identity ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(identityCmd)
+	identityCmd.Flags().BoolVarP(&identityFlags.Rotate, "rotate", "r", false,
+		"Replace the client identity by a new one.")
+}
+
+func identityCmdRun(cmd *cobra.Command, args []string) {
+	profile := utils.ViperKeepassxcProfile{}
+	if identityFlags.Rotate {
+		id, err := keepassxc.RotateClientIdentity(profile, "")
+		checkErr(err)
+		fmt.Printf("Client id: %s\n", id)
+		return
+	}
+	id, key := profile.GetClientIdentity()
+	if key == nil {
+		fmt.Println("No client identity saved yet, it is created on the next connection.")
+		return
+	}
+	fmt.Printf("Client id: %s\n", id)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// lockCmd represents the lock command
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Args:  cobra.NoArgs,
+	Run:   lockCmdRun,
+	Short: "Lock the database",
+	Long: `Lock the database.
+
+Locking an already locked database is no error, so this can be used in screen lock hooks.`,
+	Example: fmt.Sprintf("  %s lock", utils.ApplicationNameShort),
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+}
+
+func lockCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	// lock-database needs no association, so a locked database does not fail and no dialog is shown
+	client, err := keepassxcClient(ctx, keepassxc.OptSkipAssociation())
+	checkErr(err)
+	defer client.Disconnect()
+	checkErr(client.LockDatabaseContext(ctx))
+	fmt.Println("Database locked")
+}
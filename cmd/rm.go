@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// rm flags storage
+type RmFlags struct {
+	// delete all matching entries instead of selecting one
+	All bool
+	// skip the confirmation
+	Yes bool
+	// only print the entries that would be deleted
+	DryRun bool
+}
+
+// rm flags storage
+var rmFlags = RmFlags{}
+
+// rmCmd represents the rm command
+var rmCmd = &cobra.Command{
+	Use:   "rm [namefilters...]",
+	Args:  cobra.ArbitraryArgs,
+	Run:   rmCmdRun,
+	Short: "Delete entries",
+	Long: fmt.Sprintf(`Delete entries.
+
+The entry is selected the same way as for the clip command, see "%s clip -h".
+With the --all flag all matching entries are deleted instead of selecting one.
+The deletion has to be confirmed, unless the --yes flag is given.
+Keepassxc may ask to confirm the deletion of each entry as well.
+The --dry-run flag only prints the entries, that would be deleted.`,
+		utils.ApplicationNameShort,
+	),
+	Example: fmt.Sprintf("  %s rm ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"myentry", "myentry -y", "-a old -n"},
+		fmt.Sprintf("\n  %s rm ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+	rmCmd.Flags().BoolVarP(&rmFlags.All, "all", "a", false,
+		"Delete all matching entries instead of selecting one.")
+	rmCmd.Flags().BoolVarP(&rmFlags.Yes, "yes", "y", false,
+		"Delete without confirmation.")
+	rmCmd.Flags().BoolVarP(&rmFlags.DryRun, "dry-run", "n", false,
+		"Only print the entries, that would be deleted.")
+}
+
+func rmCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
+	defer client.Disconnect()
+	groups := viper.GetStringSlice(utils.ConfigKeypathClipFilterGroups)
+	var entries keepassxc.Entries
+	if rmFlags.All {
+		entries = findEntries(ctx, client, groups, args)
+	} else {
+		entries = keepassxc.Entries{selectEntry(ctx, client, groups, args)}
+	}
+
+	identifier := viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier)
+	if rmFlags.DryRun {
+		for _, entry := range entries {
+			fmt.Printf("Would delete %s\n", entry.GetCombined(identifier))
+		}
+		return
+	}
+	if !rmFlags.Yes && !confirmDeletion(entries, identifier) {
+		fmt.Println("Nothing deleted")
+		return
+	}
+
+	for _, entry := range entries {
+		ctx, cancel := keepassxcContext()
+		err := client.DeleteEntryContext(ctx, entry.Uuid)
+		cancel()
+		checkErr(err)
+		fmt.Printf("Deleted %s\n", entry.GetCombined(identifier))
+	}
+}
+
+// confirmDeletion lists the entries and asks the user to confirm their deletion on the terminal.
+func confirmDeletion(entries keepassxc.Entries, identifier []string) bool {
+	for _, entry := range entries {
+		fmt.Fprintf(os.Stderr, "  %s\n", entry.GetCombined(identifier))
+	}
+	fmt.Fprintf(os.Stderr, "Delete %d entries? [y/N] ", len(entries))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
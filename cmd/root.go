@@ -4,10 +4,14 @@ Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
 	"keepassxc-http-tools-go/pkg/utils"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,6 +24,12 @@ var Version = "dev"
 type GlobalFlags struct {
 	// path to the config file
 	ConfigFile string
+	// time limit for all keepassxc operations, 0 means no limit
+	Timeout time.Duration
+	// transport to keepassxc, overrides the config
+	Transport string
+	// show the unlock dialog and wait for the database to be unlocked
+	WaitUnlock bool
 }
 
 // global flags storage
@@ -33,9 +43,25 @@ var rootCmd = &cobra.Command{
 	Short:   "A command line client to interact with keepassxc's http api.",
 	Long: fmt.Sprintf(`A command line client to interact with keepassxc's http api.
 	
-To learn more about the config use "%s config" or "%s config -h".`,
+To learn more about the config use "%s config" or "%s config -h".
+
+Errors are reported with a hint and these exit codes:
+  %d: generic error
+  %d: keepassxc is not reachable
+  %d: the database is locked
+  %d: the action was cancelled or denied in keepassxc
+  %d: the association is not valid
+  %d: no entries match the search criteria
+  %d: the --timeout was exceeded`,
 		utils.ApplicationNameShort,
 		utils.ApplicationNameShort,
+		exitCodeGeneric,
+		exitCodeConnection,
+		exitCodeDatabaseLocked,
+		exitCodeDenied,
+		exitCodeAssociation,
+		exitCodeNoLogins,
+		exitCodeTimeout,
 	),
 }
 
@@ -52,6 +78,58 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVarP(&globalFlags.ConfigFile, "config", "c",
 		path.Join(utils.GetConfigDir(), utils.ConfigFileNameDefault), "the config file")
+	rootCmd.PersistentFlags().DurationVarP(&globalFlags.Timeout, "timeout", "T", 0,
+		"time limit for the communication with keepassxc, e.g. 30s (default no limit)")
+	rootCmd.PersistentFlags().StringVar(&globalFlags.Transport, "transport", "",
+		fmt.Sprintf("the transport to keepassxc, %q or %q (default from config or %q)",
+			utils.TransportSocket, utils.TransportProxy, utils.TransportSocket))
+	rootCmd.PersistentFlags().BoolVar(&globalFlags.WaitUnlock, "wait-unlock", false,
+		"show the unlock dialog of keepassxc, if the database is locked, and wait until it is unlocked (limited by --timeout)")
+}
+
+// keepassxcContext returns the context for keepassxc operations, limited by the global timeout flag.
+func keepassxcContext() (context.Context, context.CancelFunc) {
+	if globalFlags.Timeout > 0 {
+		return context.WithTimeout(context.Background(), globalFlags.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// keepassxcTransport returns the transport to keepassxc, the flag overrides the config.
+func keepassxcTransport() string {
+	if globalFlags.Transport != "" {
+		return globalFlags.Transport
+	}
+	return viper.GetString(utils.ConfigKeypathTransport)
+}
+
+// keepassxcClient connects to keepassxc with the configured transport, association profile and client identity.
+func keepassxcClient(ctx context.Context, options ...keepassxc.ClientOption) (*keepassxc.Client, error) {
+	switch transport := keepassxcTransport(); transport {
+	case utils.TransportSocket:
+		// the peer of the proxy transport is keepassxc-proxy, so the executable is only checked for the socket
+		if executable := viper.GetString(utils.ConfigKeypathPeerExecutable); executable != "" {
+			options = append(options, keepassxc.OptPeerExecutable(executable))
+		}
+	case utils.TransportProxy:
+		command := viper.GetStringSlice(utils.ConfigKeypathProxyCommand)
+		if len(command) == 0 {
+			return nil, fmt.Errorf("%s is empty", utils.ConfigKeypathProxyCommand)
+		}
+		options = append(options, keepassxc.OptDialer(keepassxc.ProxyDialer(command[0], command[1:]...)))
+	default:
+		return nil, fmt.Errorf("unknown transport %q, use %q or %q",
+			transport, utils.TransportSocket, utils.TransportProxy)
+	}
+	if globalFlags.WaitUnlock {
+		options = append(options, keepassxc.OptWaitForUnlock(0))
+	}
+	options = append(options, keepassxc.OptPersistentIdentity(),
+		keepassxc.OptDatabasePinning(keepassxc.DatabasePinning(viper.GetString(utils.ConfigKeypathDatabasePinning)),
+			func(err error) {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", strings.Split(err.Error(), "\n")[0])
+			}))
+	return keepassxc.NewClientContext(ctx, utils.ViperKeepassxcProfile{}, options...)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -59,6 +137,9 @@ func initConfig() {
 	viper.SetDefault(utils.ConfigKeypathEntryIdentifier, []string{"%s (%s)", "name", "login"})
 	viper.SetDefault(utils.ConfigKeypathClipDefaultCopy, []string{utils.ConfigDefaultClipDefaultCopy})
 	viper.SetDefault(utils.ConfigKeypathScriptIndicatorUrl, utils.ConfigDefaultScriptIndicatorUrl)
+	viper.SetDefault(utils.ConfigKeypathTransport, utils.TransportSocket)
+	viper.SetDefault(utils.ConfigKeypathProxyCommand, []string{utils.ConfigDefaultProxyCommand})
+	viper.SetDefault(utils.ConfigKeypathDatabasePinning, string(keepassxc.DatabasePinningOff))
 	viper.SetConfigFile(utils.ExpandUserHome(globalFlags.ConfigFile))
 	// read in environment variables that match, but only with KGHT_ prefix
 	viper.SetEnvPrefix(utils.ConfigEnvPrefix)
@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// status flags storage
+type StatusFlags struct {
+	Json bool
+}
+
+// status flags storage
+var statusFlags = StatusFlags{}
+
+// Status represents the output of the status command.
+type Status struct {
+	Transport    string `json:"transport"`
+	SocketPath   string `json:"socketPath,omitempty"`
+	Connected    bool   `json:"connected"`
+	Associated   bool   `json:"associated"`
+	DatabaseOpen bool   `json:"databaseOpen"`
+	DatabaseHash string `json:"databaseHash"`
+	Error        string `json:"error,omitempty"`
+	// the checked socket locations, only if connecting to the socket failed
+	SocketCandidates []keepassxc.SocketCandidate `json:"socketCandidates,omitempty"`
+}
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Args:  cobra.NoArgs,
+	Run:   statusCmdRun,
+	Short: "Print the connection and database status",
+	Long: `Print the connection and database status.
+
+The status contains the transport and socket path, whether the connection to keepassxc works,
+whether the association of the config is valid and the hash of the opened database.
+If the config has no association yet, none is created by this command.
+If connecting to the socket fails, all checked socket locations are listed.
+Problems are reported within the status, the command fails only if the status can not be printed.`,
+	Example: fmt.Sprintf("  %s status ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"", "-j"},
+		fmt.Sprintf("\n  %s status ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVarP(&statusFlags.Json, "json", "j", false,
+		"Print the status as json.")
+}
+
+func statusCmdRun(cmd *cobra.Command, args []string) {
+	status := getStatus()
+	if statusFlags.Json {
+		data, err := json.MarshalIndent(status, "", "  ")
+		checkErr(err)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("Transport:     %s\n", status.Transport)
+	if status.SocketPath != "" {
+		fmt.Printf("Socket path:   %s\n", status.SocketPath)
+	}
+	fmt.Printf("Connected:     %t\n", status.Connected)
+	fmt.Printf("Associated:    %t\n", status.Associated)
+	fmt.Printf("Database open: %t\n", status.DatabaseOpen)
+	fmt.Printf("Database hash: %s\n", status.DatabaseHash)
+	if status.Error != "" {
+		fmt.Printf("Error:         %s\n", strings.ReplaceAll(status.Error, "\n", ", "))
+	}
+	if len(status.SocketCandidates) > 0 {
+		fmt.Println("Socket candidates:")
+		for _, candidate := range status.SocketCandidates {
+			fmt.Printf("  %s\n", candidate)
+		}
+	}
+}
+
+// getStatus collects the status, the first error stops collecting.
+func getStatus() Status {
+	status := Status{Transport: keepassxcTransport()}
+	options := []keepassxc.ClientOption{keepassxc.OptSkipAssociation()}
+	if status.Transport == utils.TransportSocket {
+		socketPath, err := keepassxc.SocketPath()
+		status.SocketPath = socketPath
+		if err != nil {
+			var discoveryErr *keepassxc.SocketDiscoveryError
+			if errors.As(err, &discoveryErr) {
+				status.SocketCandidates = discoveryErr.Candidates
+				err = utils.ErrKeepassxcSocketNotFound
+			}
+			status.Error = err.Error()
+			return status
+		}
+		options = append(options, keepassxc.OptSocketPath(socketPath))
+	}
+
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx, options...)
+	if err != nil {
+		status.Error = err.Error()
+		if status.Transport == utils.TransportSocket && errors.Is(err, utils.ErrKeepassxcConnectFailed) {
+			status.SocketCandidates = keepassxc.DiscoverSockets(ctx)
+		}
+		return status
+	}
+	defer client.Disconnect()
+	status.Connected = true
+
+	status.DatabaseHash, err = client.GetDatabaseHashContext(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.DatabaseOpen = true
+
+	if err = client.TestAssociateContext(ctx); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Associated = true
+	return status
+}
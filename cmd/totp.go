@@ -0,0 +1,180 @@
+/*
+Copyright © 2024 Heiko Finzel heiko.finzel@wiit.cloud
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// totp flags storage
+type TotpFlags struct {
+	// minimum remaining validity of the totp in seconds, otherwise the next totp is waited for
+	MinValid int
+	// continuously show the totps of all matching entries
+	Watch bool
+}
+
+// totp flags storage
+var totpFlags = TotpFlags{}
+
+// totpCmd represents the totp command
+var totpCmd = &cobra.Command{
+	Use:   "totp [namefilters...]",
+	Args:  cobra.ArbitraryArgs,
+	Run:   totpCmdRun,
+	Short: "Print the current totp of an entry",
+	Long: fmt.Sprintf(`Print the current totp of an entry with its remaining validity.
+
+The entries from keepassxc which match the URL from config key "%s" are scanned by this command.
+If any "namefilters" arguments are given, the entries will be reduced to only those,
+which contain all the namefilters as substring in their entry names.
+If multiple entries still match, a single entry can be chosen by fuzzy finder logic.
+
+The totp is generated by keepassxc at the time of the request.
+Keepassxc does not tell the period of the totp, so the default period of %d seconds is assumed.
+If keepassxc has no totp for the entry, it is generated locally from the otpauth URI
+in the string field "%s%s" of the entry, incl. its period.
+If the totp is valid for less than the --min-valid seconds, the next totp is waited for,
+so --min-valid has to be less than the period.
+The --watch flag shows the totps of all matching entries and refreshes them until interrupted.`,
+		utils.ConfigKeypathScriptIndicatorUrl,
+		utils.TotpDefaultPeriod,
+		utils.StringFieldKeyPrefix,
+		utils.TotpStringField,
+	),
+	Example: fmt.Sprintf("  %s totp ", utils.ApplicationNameShort) + strings.Join(
+		[]string{"", "vpn", "vpn -m 10", "-w", "-w work"},
+		fmt.Sprintf("\n  %s totp ", utils.ApplicationNameShort)),
+}
+
+func init() {
+	rootCmd.AddCommand(totpCmd)
+	totpCmd.Flags().IntVarP(&totpFlags.MinValid, "min-valid", "m", 0,
+		"Wait for the next totp, if the current one is valid for less seconds.")
+	totpCmd.Flags().BoolVarP(&totpFlags.Watch, "watch", "w", false,
+		"Show the totps of all matching entries and refresh them until interrupted.")
+	totpCmd.MarkFlagsMutuallyExclusive("min-valid", "watch")
+}
+
+func totpCmdRun(cmd *cobra.Command, args []string) {
+	ctx, cancel := keepassxcContext()
+	defer cancel()
+	client, err := keepassxcClient(ctx)
+	checkErr(err)
+	defer client.Disconnect()
+
+	if totpFlags.Watch {
+		watchTotps(ctx, client, findEntries(ctx, client, nil, args))
+		return
+	}
+
+	selectedEntry := selectEntry(ctx, client, nil, args)
+	totp, remaining, period, err := currentTotp(ctx, client, selectedEntry)
+	checkErr(err)
+	if minValid := time.Duration(totpFlags.MinValid) * time.Second; remaining < minValid {
+		if minValid >= period {
+			checkErr(fmt.Errorf("--min-valid has to be less than the period of %d seconds", int(period.Seconds())))
+		}
+		fmt.Fprintf(os.Stderr, "Waiting %ds for the next totp\n", int(remaining.Seconds()+0.5))
+		select {
+		case <-time.After(remaining):
+		case <-ctx.Done():
+			checkErr(ctx.Err())
+		}
+		totp, remaining, _, err = currentTotp(ctx, client, selectedEntry)
+		checkErr(err)
+		if remaining < minValid {
+			checkErr(fmt.Errorf("the next totp is only valid for %ds, less than --min-valid", int(remaining.Seconds())))
+		}
+	}
+	fmt.Printf("%s (valid for %ds) from %s\n", totp, int(remaining.Seconds()),
+		selectedEntry.GetCombined(viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier)))
+}
+
+// totpRemaining returns how long a totp generated at the given time is valid.
+func totpRemaining(at time.Time) time.Duration {
+	period := int64(utils.TotpDefaultPeriod * time.Second)
+	return time.Duration(period - at.UnixNano()%period)
+}
+
+// currentTotp gets the totp of the entry from keepassxc and returns it with its remaining validity and its period.
+// If the period changed during the request, it is not known which period the totp belongs to, so it is requested again.
+// If keepassxc has no totp for the entry, it is generated locally, see keepassxc.Entry.TotpKey().
+func currentTotp(ctx context.Context, client *keepassxc.Client, entry *keepassxc.Entry) (string, time.Duration, time.Duration, error) {
+	for {
+		before := time.Now()
+		totp, err := client.GetTOTPContext(ctx, entry.Uuid)
+		if errors.Is(err, utils.ErrKeepassxcTotpNotFound) {
+			totpKey, keyErr := entry.TotpKey()
+			if keyErr != nil {
+				return "", 0, 0, errors.Join(err, keyErr)
+			}
+			now := time.Now()
+			return totpKey.Generate(now), totpKey.Remaining(now), totpKey.Period, nil
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if now := time.Now(); now.Sub(before) < totpRemaining(before) {
+			return totp, totpRemaining(now), utils.TotpDefaultPeriod * time.Second, nil
+		}
+	}
+}
+
+// watchTotps shows the totps of the entries and refreshes them when the first one expires, until interrupted.
+// Entries without totp are skipped.
+func watchTotps(ctx context.Context, client *keepassxc.Client, entries keepassxc.Entries) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	identifier := viper.GetStringSlice(utils.ConfigKeypathEntryIdentifier)
+
+	var lines []string
+	var expires time.Time
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if !time.Now().Before(expires) {
+			lines = lines[:0]
+			expires = time.Time{}
+			for _, entry := range entries {
+				totp, remaining, _, err := currentTotp(ctx, client, entry)
+				if errors.Is(err, utils.ErrKeepassxcTotpNotFound) {
+					continue
+				}
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				checkErr(err)
+				// refresh all totps, when the first one expires
+				if entryExpires := time.Now().Add(remaining); expires.IsZero() || entryExpires.Before(expires) {
+					expires = entryExpires
+				}
+				lines = append(lines, fmt.Sprintf("%s  %s", totp, entry.GetCombined(identifier)))
+			}
+			if len(lines) == 0 {
+				checkErr(errors.Join(errors.New("No matching entry has a totp"), utils.ErrKeepassxcTotpNotFound))
+			}
+		}
+		// clear the terminal and print the dashboard
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("Refresh in %ds (Ctrl+C to quit)\n\n%s\n",
+			int(time.Until(expires).Seconds()+0.5), strings.Join(lines, "\n"))
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
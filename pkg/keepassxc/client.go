@@ -1,12 +1,16 @@
 package keepassxc
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/kevinburke/nacl"
 	"github.com/kevinburke/nacl/box"
@@ -15,16 +19,37 @@ import (
 	"keepassxc-http-tools-go/pkg/utils"
 )
 
+// Client represents a connection to the keepassxc http api.
+// It is safe for concurrent use, concurrent requests share the encrypted session
+// and their responses are correlated by the reader goroutine, see dispatcher.
 type Client struct {
 	Id              string
 	SocketPath      string
 	ApplicationName string
 	AssocProfile    KeepassxcClientProfile
 
-	socket     net.Conn
 	privateKey nacl.Key
 	publicKey  nacl.Key
-	peerKey    nacl.Key
+	events     chan Event
+	reconnect  *ReconnectPolicy
+	dialer     Dialer
+
+	peerExecutable string
+	pinning        DatabasePinning
+	pinningWarn    func(error)
+	waitUnlock     bool
+	unlockTimeout  time.Duration
+
+	skipAssociation bool
+	persistIdentity bool
+
+	// mu guards the current session and the closed state
+	mu       sync.Mutex
+	session  *session
+	closed   bool
+	closedCh chan struct{}
+	// reconnectMu serializes reconnects
+	reconnectMu sync.Mutex
 }
 
 /*
@@ -56,14 +81,44 @@ func OptSocketPath(path string) ClientOption {
 	}
 }
 
+// OptSkipAssociation is an option to NewClient.
+// It skips the association (or its test) with the profile, so no association dialog is shown in keepassxc.
+// Only actions that need no association work then, e.g. GetDatabaseHash. See also TestAssociate.
+// The profile is not modified then, a missing persistent identity is not saved either, see OptPersistentIdentity.
+func OptSkipAssociation() ClientOption {
+	return func(client *Client) error {
+		client.skipAssociation = true
+		return nil
+	}
+}
+
+// OptPersistentIdentity is an option to NewClient.
+// The client id and key pair are loaded from the profile, which has to implement KeepassxcClientIdentityProfile,
+// instead of generating new ones on each connection. If the profile has no identity yet, a new one is saved.
+// See also RotateClientIdentity.
+func OptPersistentIdentity() ClientOption {
+	return func(client *Client) error {
+		client.persistIdentity = true
+		return nil
+	}
+}
+
 // NewClient creates a new keepassxc http api client and connect to its socket.
+// See NewClientContext.
 func NewClient(assocProfile KeepassxcClientProfile, options ...ClientOption) (*Client, error) {
+	return NewClientContext(context.Background(), assocProfile, options...)
+}
+
+// NewClientContext creates a new keepassxc http api client and connect to its socket.
+// The context limits the connection, the key exchange and the association (which may show a dialog in keepassxc).
+// If any of them fails, the connection is closed and no client is returned.
+func NewClientContext(ctx context.Context, assocProfile KeepassxcClientProfile, options ...ClientOption) (*Client, error) {
 	var err error
 	client := &Client{
 		AssocProfile: assocProfile,
-		privateKey:   nacl.NewKey(),
+		events:       make(chan Event, eventBufferSize),
+		closedCh:     make(chan struct{}),
 	}
-	client.publicKey = scalarmult.Base(client.privateKey)
 
 	for _, option := range options {
 		if err = option(client); err != nil {
@@ -75,193 +130,676 @@ func NewClient(assocProfile KeepassxcClientProfile, options ...ClientOption) (*C
 		client.ApplicationName = utils.ApplicationName
 	}
 
-	if client.SocketPath == "" {
-		if client.SocketPath, err = SocketPath(); err != nil {
+	if client.dialer == nil {
+		if client.SocketPath == "" {
+			if client.SocketPath, err = SocketPath(); err != nil {
+				return nil, err
+			}
+		}
+		client.dialer = SocketDialer(client.SocketPath)
+	}
+	if client.peerExecutable != "" {
+		client.dialer = peerExecutableDialer(client.dialer, client.peerExecutable)
+	}
+
+	if client.persistIdentity {
+		if err = client.loadIdentity(); err != nil {
 			return nil, err
 		}
+	} else {
+		client.Id, client.privateKey = newClientIdentity(client.ApplicationName)
 	}
+	client.publicKey = scalarmult.Base(client.privateKey)
 
-	client.Id = client.ApplicationName + utils.NaclNonceToB64(nacl.NewNonce())
-	if client.socket, err = connect(client.SocketPath); err != nil {
+	if client.session, err = client.dial(ctx); err != nil {
 		return nil, err
 	}
-	if err = client.exchangePublicKeys(); err != nil {
+	if client.skipAssociation {
+		return client, nil
+	}
+	if err = client.ensureAssociation(ctx, client.session); err != nil {
+		client.Disconnect()
 		return nil, err
 	}
-	if client.AssocProfile.GetAssocKey() == nil {
-		err = client.associate()
-	} else {
-		err = client.testAssociate()
+	return client, nil
+}
+
+// loadIdentity is a helper function for NewClient.
+// It loads the client id and private key from the profile, a new identity is saved if there is none.
+func (c *Client) loadIdentity() error {
+	profile, ok := c.AssocProfile.(KeepassxcClientIdentityProfile)
+	if !ok {
+		return errors.Join(errors.New("the profile can not persist the client identity"), utils.ErrKeepassxcIdentityFailed)
+	}
+	if c.Id, c.privateKey = profile.GetClientIdentity(); c.Id != "" && c.privateKey != nil {
+		return nil
 	}
-	return client, err
+	if c.skipAssociation {
+		c.Id, c.privateKey = newClientIdentity(c.ApplicationName)
+		return nil
+	}
+	var err error
+	if c.Id, err = RotateClientIdentity(profile, c.ApplicationName); err != nil {
+		return err
+	}
+	_, c.privateKey = profile.GetClientIdentity()
+	return nil
 }
 
-// exchangePublicKeys is a helper function for NewClient.
+// newClientIdentity generates a new client id and private key.
+func newClientIdentity(applicationName string) (string, nacl.Key) {
+	return applicationName + utils.NaclNonceToB64(nacl.NewNonce()), nacl.NewKey()
+}
+
+// RotateClientIdentity replaces the client identity saved in the profile by a new one and returns the new client id.
+// If applicationName is empty, utils.ApplicationName is used. Connected clients keep their identity.
+func RotateClientIdentity(profile KeepassxcClientIdentityProfile, applicationName string) (string, error) {
+	if applicationName == "" {
+		applicationName = utils.ApplicationName
+	}
+	id, key := newClientIdentity(applicationName)
+	if err := profile.SetClientIdentity(id, key); err != nil {
+		return "", errors.Join(err, utils.ErrKeepassxcIdentityFailed)
+	}
+	return id, nil
+}
+
+// dial is a helper function for NewClient and reconnects.
+// It connects with the Dialer and exchanges encryption keys for a new session.
+func (c *Client) dial(ctx context.Context) (*session, error) {
+	socket, err := c.dialer(ctx)
+	if err != nil {
+		return nil, errors.Join(err, utils.ErrKeepassxcConnectFailed)
+	}
+	s := newSession(socket, c.events)
+	if err = c.exchangePublicKeys(ctx, s); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// exchangePublicKeys is a helper function for dial.
 // It exchanges encryption keys with the server.
-func (c *Client) exchangePublicKeys() error {
-	resp, err := c.sendMessage(Message{
-		"action":    "change-public-keys",
-		"publicKey": utils.NaclKeyToB64(c.publicKey),
-	}, false)
+func (c *Client) exchangePublicKeys(ctx context.Context, s *session) error {
+	resp, err := c.sendPlain(ctx, s, &Envelope{
+		Action:    utils.ActionChangePublicKeys,
+		PublicKey: utils.NaclKeyToB64(c.publicKey),
+	})
 	if err != nil {
-		return err
+		return errors.Join(err, utils.ErrKeepassxcKeyExchangeFailed)
 	}
-	if peerKey, ok := resp["publicKey"]; ok {
-		c.peerKey = utils.B64ToNaclKey(peerKey.(string))
-		return nil
+	// utils.B64ToNaclKey pads short keys, so the decoded length is checked first
+	if decoded, err := base64.StdEncoding.DecodeString(resp.PublicKey); err != nil || len(decoded) != nacl.KeySize {
+		return errors.Join(utils.ErrKeepassxcInvalidResponse, utils.ErrKeepassxcKeyExchangeFailed)
 	}
-	return utils.ErrKeepassxcKeyExchangeFailed
+	s.peerKey = utils.B64ToNaclKey(resp.PublicKey)
+	return nil
 }
 
-// associate is a helper function for NewClient.
-// It tells the server to associate the key with the given profile.
-func (c *Client) associate() error {
-	assocKey := nacl.NewKey()
-	resp, err := c.sendMessage(Message{
-		"action": "associate",
-		"key":    utils.NaclKeyToB64(c.publicKey),
-		"idKey":  utils.NaclKeyToB64(assocKey),
-	}, true)
+// ensureAssociation is a helper function for NewClient and reconnects.
+// It tests the association of the profile with the current database, or creates it if there is none.
+// For a KeepassxcMultiDatabaseProfile the association is looked up by the database hash,
+// an association of the plain KeepassxcClientProfile methods is taken over for the database, if it is valid.
+// The database is checked against the pinned databases first, see OptDatabasePinning.
+// A locked database is waited for, see OptWaitForUnlock.
+func (c *Client) ensureAssociation(ctx context.Context, s *session) error {
+	if c.waitUnlock {
+		if err := c.waitForUnlock(ctx, s, c.unlockTimeout); err != nil {
+			return err
+		}
+	}
+	assoc, hash, err := c.currentAssociation(ctx, s)
 	if err != nil {
 		return err
 	}
-	if v, ok := resp["message"]; ok {
-		if msg, ok := v.(map[string]interface{}); ok {
-			if id, ok := msg["id"]; ok {
-				if err = c.AssocProfile.SetAssoc(id.(string), assocKey); err != nil {
-					return errors.Join(err, utils.ErrKeepassxcAssocFailed)
-				}
-				return nil
+	if err = c.checkDatabasePinning(hash); err != nil {
+		return err
+	}
+	if assoc != nil {
+		if err = c.testAssociate(ctx, s, *assoc); err != nil {
+			return err
+		}
+		return c.pinDatabase(hash)
+	}
+	if profile, ok := c.AssocProfile.(KeepassxcMultiDatabaseProfile); ok && profile.GetAssocKey() != nil {
+		legacy := association{name: profile.GetAssocName(), key: profile.GetAssocKey()}
+		if c.testAssociate(ctx, s, legacy) == nil {
+			if err = profile.SetDatabaseAssoc(hash, legacy.name, legacy.key); err != nil {
+				return errors.Join(err, utils.ErrKeepassxcAssocFailed)
 			}
+			return c.pinDatabase(hash)
+		}
+	}
+	return c.associate(ctx, s, hash)
+}
+
+// currentAssociation returns the association of the profile with the current database, or nil if there is none.
+// The database hash is only determined (and returned) for a KeepassxcMultiDatabaseProfile.
+func (c *Client) currentAssociation(ctx context.Context, s *session) (*association, string, error) {
+	profile, ok := c.AssocProfile.(KeepassxcMultiDatabaseProfile)
+	if !ok {
+		if c.AssocProfile.GetAssocKey() == nil {
+			return nil, "", nil
 		}
+		return &association{name: c.AssocProfile.GetAssocName(), key: c.AssocProfile.GetAssocKey()}, "", nil
+	}
+	hash, err := c.databaseHash(ctx, s)
+	if err != nil {
+		return nil, "", err
+	}
+	if name, key := profile.GetDatabaseAssoc(hash); key != nil {
+		return &association{name: name, key: key}, hash, nil
+	}
+	return nil, hash, nil
+}
+
+// associations returns all associations of the profile, to query all known databases.
+// If databases are pinned, only their associations are returned, see OptDatabasePinning.
+func (c *Client) associations() []association {
+	profile, ok := c.AssocProfile.(KeepassxcMultiDatabaseProfile)
+	if !ok {
+		return []association{{name: c.AssocProfile.GetAssocName(), key: c.AssocProfile.GetAssocKey()}}
+	}
+	pinned := c.pinnedDatabases()
+	var assocs []association
+	for _, hash := range profile.GetDatabaseHashes() {
+		if len(pinned) > 0 && !slices.Contains(pinned, hash) {
+			continue
+		}
+		if name, key := profile.GetDatabaseAssoc(hash); key != nil {
+			assocs = append(assocs, association{name: name, key: key})
+		}
+	}
+	return assocs
+}
+
+// associate is a helper function for ensureAssociation.
+// It tells the server to associate a new key with the given profile.
+// The hash identifies the database for a KeepassxcMultiDatabaseProfile.
+func (c *Client) associate(ctx context.Context, s *session, hash string) error {
+	assocKey := nacl.NewKey()
+	var resp AssociateResponse
+	err := c.sendMessage(ctx, s, &AssociateRequest{
+		Action: utils.ActionAssociate,
+		Key:    utils.NaclKeyToB64(c.publicKey),
+		IdKey:  utils.NaclKeyToB64(assocKey),
+	}, &resp)
+	if err != nil {
+		return errors.Join(err, utils.ErrKeepassxcAssocFailed)
+	}
+	if profile, ok := c.AssocProfile.(KeepassxcMultiDatabaseProfile); ok {
+		err = profile.SetDatabaseAssoc(hash, resp.Id, assocKey)
+	} else {
+		err = c.AssocProfile.SetAssoc(resp.Id, assocKey)
 	}
-	return utils.ErrKeepassxcAssocFailed
+	if err != nil {
+		return errors.Join(err, utils.ErrKeepassxcAssocFailed)
+	}
+	return c.pinDatabase(hash)
 }
 
-// testAssociate is a helper function for NewClient.
-// It tests the association if an assocKey is already present in the profile.
-func (c *Client) testAssociate() error {
-	if _, err := c.sendMessage(Message{
-		"action": "test-associate",
-		"key":    utils.NaclKeyToB64(c.AssocProfile.GetAssocKey()),
-		"id":     c.AssocProfile.GetAssocName(),
-	}, true); err != nil {
+// testAssociate is a helper function for ensureAssociation.
+// It tests the given association.
+func (c *Client) testAssociate(ctx context.Context, s *session, assoc association) error {
+	if err := c.sendMessage(ctx, s, &TestAssociateRequest{
+		Action: utils.ActionTestAssociate,
+		Key:    utils.NaclKeyToB64(assoc.key),
+		Id:     assoc.name,
+	}, &TestAssociateResponse{}); err != nil {
 		return errors.Join(err, utils.ErrKeepassxcTestAssocFailed)
 	}
 	return nil
 }
 
+// TestAssociate tests the association of the profile with the database.
+// See TestAssociateContext.
+func (c *Client) TestAssociate() error {
+	return c.TestAssociateContext(context.Background())
+}
+
+// TestAssociateContext tests the association of the profile with the database.
+// This is done by NewClient already, unless OptSkipAssociation is used.
+func (c *Client) TestAssociateContext(ctx context.Context) error {
+	s := c.currentSession()
+	assoc, _, err := c.currentAssociation(ctx, s)
+	if err != nil {
+		return errors.Join(err, utils.ErrKeepassxcTestAssocFailed)
+	}
+	if assoc == nil {
+		return utils.ErrKeepassxcTestAssocFailed
+	}
+	return c.testAssociate(ctx, s, *assoc)
+}
+
 // Disconnect from the keepassxc http api socket.
+// This stops the reader goroutine and closes the channel returned by Events().
 func (c *Client) Disconnect() error {
-	if c.socket != nil {
-		return c.socket.Close()
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
 	}
-	return nil
+	c.closed = true
+	close(c.closedCh)
+	c.mu.Unlock()
+
+	// wait for a running reconnect, it stops because of closedCh
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	err := c.currentSession().close()
+	close(c.events)
+	return err
+}
+
+// currentSession returns the session to send requests with.
+func (c *Client) currentSession() *session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// Events returns the channel of unsolicited messages pushed by the api,
+// e.g. utils.ActionDatabaseLocked and utils.ActionDatabaseUnlocked notifications.
+// Events are dropped if the channel is not read fast enough.
+// The channel is closed by Disconnect().
+func (c *Client) Events() <-chan Event {
+	return c.events
 }
 
 /*
 	Messaging implementation
 */
 
-// encryptMessage encrypts the given message.
-func (c *Client) encryptMessage(msg Message) ([]byte, error) {
-	msgData, err := json.Marshal(msg)
+// encryptMessage encrypts the given request for the session.
+func (c *Client) encryptMessage(s *session, req Request) ([]byte, error) {
+	msgData, err := json.Marshal(req)
 	if err != nil {
 		return nil, errors.Join(err, utils.ErrKeepassxcEncryptionFailed)
 	}
-	return box.EasySeal(msgData, c.peerKey, c.privateKey), nil
+	return box.EasySeal(msgData, s.peerKey, c.privateKey), nil
 }
 
-// decryptResponse decrypts the given message.
-func (c *Client) decryptResponse(encryptedMsg []byte) ([]byte, error) {
-	msg, err := box.EasyOpen(encryptedMsg, c.peerKey, c.privateKey)
+// decryptResponse decrypts the given message of the session.
+func (c *Client) decryptResponse(s *session, encryptedMsg []byte) ([]byte, error) {
+	msg, err := box.EasyOpen(encryptedMsg, s.peerKey, c.privateKey)
 	if err != nil {
 		return msg, errors.Join(err, utils.ErrKeepassxcDecryptionFailed)
 	}
 	return msg, nil
 }
 
-// sendMessage implements the generic message sendig to the api.
-func (c *Client) sendMessage(msg Message, encrypted bool) (Response, error) {
-	if encrypted {
-		encryptedMsg, err := c.encryptMessage(msg)
-		if err != nil {
-			return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
-		}
-		msg = Message{
-			"action":  msg["action"],
-			"message": base64.StdEncoding.EncodeToString(encryptedMsg[nacl.NonceSize:]),
-			"nonce":   base64.StdEncoding.EncodeToString(encryptedMsg[:nacl.NonceSize]),
-		}
-	} else {
-		msg["nonce"] = utils.NaclNonceToB64(nacl.NewNonce())
+// sendPlain sends an unencrypted envelope within the given session, this is only used for the key exchange.
+func (c *Client) sendPlain(ctx context.Context, s *session, env *Envelope) (*Envelope, error) {
+	resp, err := c.exchange(ctx, s, env, nacl.NewNonce())
+	if err != nil {
+		return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
 	}
-	msg["clientID"] = c.Id
+	return resp, nil
+}
 
-	data, err := json.Marshal(msg)
+// sendMessage sends the encrypted request within the given session and decrypts the response into resp.
+// Responses, that can not be parsed or miss required fields, fail with utils.ErrKeepassxcInvalidResponse.
+func (c *Client) sendMessage(ctx context.Context, s *session, req Request, resp Response) error {
+	encryptedMsg, err := c.encryptMessage(s, req)
 	if err != nil {
-		return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+		return errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+	}
+	nonce := new([nacl.NonceSize]byte)
+	copy(nonce[:], encryptedMsg[:nacl.NonceSize])
+	env := &Envelope{
+		Action:  req.RequestAction(),
+		Message: base64.StdEncoding.EncodeToString(encryptedMsg[nacl.NonceSize:]),
+	}
+	if envReq, ok := req.(envelopeRequest); ok {
+		envReq.envelope(env)
+	}
+	respEnv, err := c.exchange(ctx, s, env, nonce)
+	if err != nil {
+		return errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
 	}
 
-	_, err = c.socket.Write(data)
+	respNonce, err := base64.StdEncoding.DecodeString(respEnv.Nonce)
 	if err != nil {
-		return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+		return errors.Join(err, utils.ErrKeepassxcInvalidResponse, utils.ErrKeepassxcSendMessageFailed)
+	}
+	respMsg, err := base64.StdEncoding.DecodeString(respEnv.Message)
+	if err != nil || len(respMsg) == 0 {
+		return errors.Join(err, utils.ErrKeepassxcInvalidResponse, utils.ErrKeepassxcSendMessageFailed)
+	}
+	decryptedMsg, err := c.decryptResponse(s, append(respNonce, respMsg...))
+	if err != nil {
+		return errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+	}
+	if err = json.Unmarshal(decryptedMsg, resp); err != nil {
+		return errors.Join(err, utils.ErrKeepassxcInvalidResponse, utils.ErrKeepassxcSendMessageFailed)
+	}
+	// the encrypted message repeats the nonce, it has to match as well
+	if msgNonce := resp.Header().Nonce; msgNonce != "" && msgNonce != respEnv.Nonce {
+		return errors.Join(fmt.Errorf("expected message nonce %s, got %s", respEnv.Nonce, msgNonce),
+			utils.ErrKeepassxcNonceMismatch, utils.ErrKeepassxcSendMessageFailed)
 	}
+	if err = resp.Validate(); err != nil {
+		return errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+	}
+	return nil
+}
 
-	buf := make([]byte, 4096)
-	count, err := c.socket.Read(buf)
+// exchange implements the generic message sendig to the api within the given session.
+// The response is correlated to the request by the reader goroutine, see dispatcher.
+// The context aborts waiting for the response, a late response will be discarded.
+func (c *Client) exchange(ctx context.Context, s *session, env *Envelope, nonce nacl.Nonce) (*Envelope, error) {
+	env.Nonce = utils.NaclNonceToB64(nonce)
+	env.ClientID = c.Id
+	data, err := json.Marshal(env)
 	if err != nil {
-		return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+		return nil, err
 	}
-	buf = buf[0:count]
 
-	var resp Response
-	err = json.Unmarshal(buf, &resp)
+	req, err := s.send(ctx, data, env.Action, utils.NaclNonceToB64(utils.IncrementNonce(nonce)))
 	if err != nil {
-		return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+		if ctx.Err() == nil {
+			err = errors.Join(err, utils.ErrKeepassxcConnectionClosed)
+		}
+		return nil, err
+	}
+
+	select {
+	case <-req.done:
+	case <-ctx.Done():
+		// the request stays registered, so its late response is consumed without affecting other requests
+		return nil, ctx.Err()
+	}
+	if req.err != nil {
+		return nil, req.err
+	}
+	resp := req.resp
+
+	if err = resp.protocolError(); err != nil {
+		return nil, err
 	}
 
-	if err, ok := resp["error"]; ok {
-		return nil, errors.Join(fmt.Errorf("%v %s", resp["errorCode"], err.(string)),
-			utils.ErrKeepassxcSendMessageFailed)
+	// the api answers with the incremented request nonce, anything else is replayed or misrouted
+	if resp.Nonce != req.nonce {
+		return nil, errors.Join(fmt.Errorf("expected nonce %s, got %q", req.nonce, resp.Nonce),
+			utils.ErrKeepassxcNonceMismatch)
 	}
+	return resp, nil
+}
 
-	if encrypted {
-		decoded, err := base64.StdEncoding.DecodeString(resp["nonce"].(string) + resp["message"].(string))
-		if err != nil {
-			return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+// request sends an encrypted request with the current session and decrypts the response into resp.
+// If a ReconnectPolicy is set and the connection is lost, the session is re-established
+// and idempotent requests are retried, see OptReconnect.
+// Idempotent requests failing because of a locked database are retried after the unlock, see OptWaitForUnlock.
+func (c *Client) request(ctx context.Context, req Request, resp Response) error {
+	for {
+		s := c.currentSession()
+		err := c.sendMessage(ctx, s, req, resp)
+		// lock-database answers with "database not opened" on success, so it is not waited for
+		if err != nil && c.waitUnlock && ctx.Err() == nil && idempotentActions[req.RequestAction()] &&
+			req.RequestAction() != utils.ActionLockDatabase && errors.Is(err, utils.ErrKeepassxcDatabaseNotOpened) {
+			if unlockErr := c.waitForUnlock(ctx, s, c.unlockTimeout); unlockErr != nil {
+				return errors.Join(err, unlockErr)
+			}
+			continue
+		}
+		if err == nil || c.reconnect == nil || ctx.Err() != nil ||
+			!errors.Is(err, utils.ErrKeepassxcConnectionClosed) {
+			return err
 		}
-		decryptedMsg, err := c.decryptResponse(decoded)
-		if err != nil {
-			return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+		if reconnectErr := c.reconnectSession(ctx, s); reconnectErr != nil {
+			return errors.Join(err, reconnectErr)
 		}
-		var msg map[string]interface{}
-		err = json.Unmarshal(decryptedMsg, &msg)
-		if err != nil {
-			return nil, errors.Join(err, utils.ErrKeepassxcSendMessageFailed)
+		if !idempotentActions[req.RequestAction()] {
+			return err
 		}
-		resp["message"] = msg
 	}
-
-	return resp, nil
 }
 
 // GetLogins finds all data sets for the given url.
+// See GetLoginsContext.
 func (c *Client) GetLogins(url string) (Entries, error) {
-	msg := Message{
-		"action": "get-logins",
-		"url":    url,
-		"keys": []map[string]string{
-			{
-				"id":  c.AssocProfile.GetAssocName(),
-				"key": utils.NaclKeyToB64(c.AssocProfile.GetAssocKey()),
-			},
-		},
-	}
-	resp, err := c.sendMessage(msg, true)
+	return c.GetLoginsContext(context.Background(), url)
+}
+
+// GetLoginsContext finds all data sets for the given url.
+// The context limits the time to wait for keepassxc, e.g. if it shows an access confirmation dialog.
+// With a KeepassxcMultiDatabaseProfile the entries of all associated and opened databases are returned.
+func (c *Client) GetLoginsContext(ctx context.Context, url string) (Entries, error) {
+	assocs := c.associations()
+	keys := make([]AssociationKey, len(assocs))
+	for i, assoc := range assocs {
+		keys[i] = AssociationKey{
+			Id:  assoc.name,
+			Key: utils.NaclKeyToB64(assoc.key),
+		}
+	}
+	var resp GetLoginsResponse
+	err := c.request(ctx, &GetLoginsRequest{
+		Action: utils.ActionGetLogins,
+		Url:    url,
+		Keys:   keys,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// GetLoginsMulti finds all data sets for each of the given urls.
+// See GetLoginsMultiContext.
+func (c *Client) GetLoginsMulti(urls ...string) (map[string]Entries, error) {
+	return c.GetLoginsMultiContext(context.Background(), urls...)
+}
+
+// GetLoginsMultiContext finds all data sets for each of the given urls, the requests are sent concurrently.
+// Urls without data sets map to empty Entries. The first other error cancels the remaining requests and is returned.
+func (c *Client) GetLoginsMultiContext(ctx context.Context, urls ...string) (map[string]Entries, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	results := make(map[string]Entries, len(urls))
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			entries, err := c.GetLoginsContext(ctx, url)
+			if errors.Is(err, utils.ErrKeepassxcNoLoginsFound) {
+				entries, err = Entries{}, nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			results[url] = entries
+		}(url)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// GeneratePassword lets keepassxc generate a password with the generator settings configured there.
+// See GeneratePasswordContext.
+func (c *Client) GeneratePassword() (Password, error) {
+	return c.GeneratePasswordContext(context.Background())
+}
+
+// GeneratePasswordContext lets keepassxc generate a password with the generator settings configured there.
+// Newer keepassxc versions show the generator dialog and answer only after the user accepted a password,
+// the context limits the time to wait for that.
+func (c *Client) GeneratePasswordContext(ctx context.Context) (Password, error) {
+	var resp GeneratePasswordResponse
+	if err := c.request(ctx, &ActionRequest{Action: utils.ActionGeneratePassword}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Generated(), nil
+}
+
+// SetLogin creates a new entry or updates an existing one, if login.Uuid is set.
+// See SetLoginContext.
+func (c *Client) SetLogin(login LoginData) error {
+	return c.SetLoginContext(context.Background(), login)
+}
+
+// SetLoginContext creates a new entry or updates an existing one, if login.Uuid is set.
+// Keepassxc may ask the user to confirm an update, the context limits the time to wait for that.
+func (c *Client) SetLoginContext(ctx context.Context, login LoginData) error {
+	id, err := c.currentAssociationId(ctx)
+	if err != nil {
+		return err
+	}
+	return c.request(ctx, &SetLoginRequest{
+		Action:    utils.ActionSetLogin,
+		Url:       login.Url,
+		SubmitUrl: login.Url,
+		Id:        id,
+		Login:     login.Login,
+		Password:  login.Password,
+		Group:     login.Group,
+		GroupUuid: login.GroupUuid,
+		Uuid:      login.Uuid,
+	}, &SetLoginResponse{})
+}
+
+// currentAssociationId returns the association id of the profile with the currently opened database.
+// For a KeepassxcMultiDatabaseProfile it is looked up by the database hash like in currentAssociation,
+// but the hash is requested with reconnects.
+func (c *Client) currentAssociationId(ctx context.Context) (string, error) {
+	profile, ok := c.AssocProfile.(KeepassxcMultiDatabaseProfile)
+	if !ok {
+		return c.AssocProfile.GetAssocName(), nil
+	}
+	hash, err := c.GetDatabaseHashContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	name, _ := profile.GetDatabaseAssoc(hash)
+	return name, nil
+}
+
+// GetDatabaseGroups returns the group tree of the database.
+// See GetDatabaseGroupsContext.
+func (c *Client) GetDatabaseGroups() (Groups, error) {
+	return c.GetDatabaseGroupsContext(context.Background())
+}
+
+// GetDatabaseGroupsContext returns the group tree of the database.
+// The returned groups are the root groups, the Path of each group is filled.
+func (c *Client) GetDatabaseGroupsContext(ctx context.Context) (Groups, error) {
+	var resp GetDatabaseGroupsResponse
+	if err := c.request(ctx, &ActionRequest{Action: utils.ActionGetDatabaseGroups}, &resp); err != nil {
+		return nil, err
+	}
+	groups := resp.Groups.Groups
+	groups.setPaths("")
+	return groups, nil
+}
+
+// CreateNewGroup creates the group with the given path, e.g. "/scripts/clip", incl. missing parents.
+// See CreateNewGroupContext.
+func (c *Client) CreateNewGroup(path string) (*Group, error) {
+	return c.CreateNewGroupContext(context.Background(), path)
+}
+
+// CreateNewGroupContext creates the group with the given path, e.g. "/scripts/clip", incl. missing parents.
+// If the group already exists, it is returned.
+func (c *Client) CreateNewGroupContext(ctx context.Context, path string) (*Group, error) {
+	var resp CreateNewGroupResponse
+	err := c.request(ctx, &CreateNewGroupRequest{
+		Action:    utils.ActionCreateNewGroup,
+		GroupName: path,
+	}, &resp)
 	if err != nil {
 		return nil, err
 	}
+	return &Group{Name: resp.Name, Uuid: resp.Uuid, Path: "/" + strings.Trim(path, "/")}, nil
+}
+
+// GetDatabaseHash returns the hash identifying the currently opened database.
+// See GetDatabaseHashContext.
+func (c *Client) GetDatabaseHash() (string, error) {
+	return c.GetDatabaseHashContext(context.Background())
+}
+
+// GetDatabaseHashContext returns the hash identifying the currently opened database.
+// It fails with utils.ErrKeepassxcDatabaseNotOpened, if the database is locked.
+func (c *Client) GetDatabaseHashContext(ctx context.Context) (string, error) {
+	var resp GetDatabaseHashResponse
+	if err := c.request(ctx, &GetDatabaseHashRequest{Action: utils.ActionGetDatabaseHash}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Hash, nil
+}
+
+// databaseHash is the GetDatabaseHash implementation for the given session, without reconnects.
+func (c *Client) databaseHash(ctx context.Context, s *session) (string, error) {
+	var resp GetDatabaseHashResponse
+	if err := c.sendMessage(ctx, s, &GetDatabaseHashRequest{Action: utils.ActionGetDatabaseHash}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Hash, nil
+}
+
+// GetTOTP returns the current totp of the entry with the given UUID.
+// See GetTOTPContext.
+func (c *Client) GetTOTP(uuid string) (string, error) {
+	return c.GetTOTPContext(context.Background(), uuid)
+}
 
-	return resp.entries()
+// GetTOTPContext returns the current totp of the entry with the given UUID.
+// Unlike Entry.Totp of GetLogins, the totp is generated at the time of the request.
+// If the entry has no totp set up, this fails with utils.ErrKeepassxcTotpNotFound.
+func (c *Client) GetTOTPContext(ctx context.Context, uuid string) (string, error) {
+	var resp GetTOTPResponse
+	if err := c.request(ctx, &GetTOTPRequest{Action: utils.ActionGetTotp, Uuid: uuid}, &resp); err != nil {
+		return "", err
+	}
+	if resp.Totp == "" {
+		return "", errors.Join(fmt.Errorf("no totp for entry %s", uuid), utils.ErrKeepassxcTotpNotFound)
+	}
+	return resp.Totp, nil
+}
+
+// DeleteEntry deletes the entry with the given UUID.
+// See DeleteEntryContext.
+func (c *Client) DeleteEntry(uuid string) error {
+	return c.DeleteEntryContext(context.Background(), uuid)
+}
+
+// DeleteEntryContext deletes the entry with the given UUID.
+// Keepassxc asks the user to confirm the deletion, the context limits the time to wait for that.
+// If the entry is not found or the deletion is denied, this fails with utils.ErrKeepassxcActionCancelledOrDenied.
+// The request is not retried after a reconnect, since it may have been executed already.
+func (c *Client) DeleteEntryContext(ctx context.Context, uuid string) error {
+	return c.request(ctx, &DeleteEntryRequest{Action: utils.ActionDeleteEntry, Uuid: uuid}, &DeleteEntryResponse{})
+}
+
+// LockDatabase locks the currently opened database.
+// See LockDatabaseContext.
+func (c *Client) LockDatabase() error {
+	return c.LockDatabaseContext(context.Background())
+}
+
+// LockDatabaseContext locks the currently opened database.
+// Locking an already locked database is no error.
+func (c *Client) LockDatabaseContext(ctx context.Context) error {
+	err := c.request(ctx, &ActionRequest{Action: utils.ActionLockDatabase}, &LockDatabaseResponse{})
+	// keepassxc answers with "database not opened" after locking the database
+	if errors.Is(err, utils.ErrKeepassxcDatabaseNotOpened) {
+		return nil
+	}
+	return err
 }
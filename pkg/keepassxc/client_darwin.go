@@ -4,6 +4,8 @@
 package keepassxc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"keepassxc-http-tools-go/pkg/utils"
 	"net"
@@ -11,21 +13,30 @@ import (
 	"path/filepath"
 )
 
-// SocketPath tries to find the path to the socket of the keepassxc http api - MacOS version
-func SocketPath() (string, error) {
+// socketCandidates returns the possible locations of the socket of the keepassxc http api - MacOS version
+func socketCandidates() []SocketCandidate {
+	source := "$" + utils.DarwinEnvTmpDir
 	tmpDir, ok := os.LookupEnv(utils.DarwinEnvTmpDir)
 	if !ok {
-		return "", fmt.Errorf("%w, $%s not set", utils.ErrKeepassxcSocketNotFound, utils.DarwinEnvTmpDir)
+		return []SocketCandidate{{Source: source, Status: SocketError, Error: fmt.Sprintf("$%s not set", utils.DarwinEnvTmpDir)}}
 	}
+	return []SocketCandidate{{Path: filepath.Join(tmpDir, utils.SocketFileName), Source: source}}
+}
 
-	path := filepath.Join(tmpDir, utils.SocketFileName)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return "", utils.ErrKeepassxcSocketNotFound
-	}
-	return path, nil
+// isSocket checks the file mode of a socket candidate - MacOS version
+func isSocket(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSocket != 0
 }
 
 // connect implements the os specific socket connection action - MacOS version
-func connect(socketPath string) (net.Conn, error) {
-	return net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+func connect(ctx context.Context, socketPath string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", socketPath)
+}
+
+// verifyPeerExecutable checks the executable of the peer process of the connection - MacOS version
+// This is not supported, so it always fails.
+func verifyPeerExecutable(conn net.Conn, executable string) error {
+	return errors.Join(errors.New("peer executable verification is only supported on linux"),
+		utils.ErrKeepassxcPeerVerificationFailed)
 }
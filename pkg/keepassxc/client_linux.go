@@ -4,46 +4,117 @@
 package keepassxc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"keepassxc-http-tools-go/pkg/utils"
 	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"syscall"
 )
 
-// SocketPath tries to find the path to the socket of the keepassxc http api - Linux version
-func SocketPath() (string, error) {
-	userHome, err := os.UserHomeDir()
+// socketCandidates returns the possible locations of the socket of the keepassxc http api - Linux version
+func socketCandidates() []SocketCandidate {
+	runtimeDir := utils.GetEnvWithDefault(utils.LinuxEnvXdgRuntimeDir, fmt.Sprintf("/run/user/%d/", os.Getuid()))
+	var candidates []SocketCandidate
+	if userHome, err := os.UserHomeDir(); err != nil {
+		candidates = append(candidates, SocketCandidate{Source: "snap", Status: SocketError, Error: err.Error()})
+	} else {
+		candidates = append(candidates, SocketCandidate{
+			Path:   path.Join(userHome, utils.LinuxSnapCommonSubdir, utils.SocketFileName),
+			Source: "snap",
+		})
+	}
+	return append(candidates,
+		SocketCandidate{
+			Path:   path.Join(runtimeDir, utils.LinuxFlatpakAppSubdir, utils.SocketFileName),
+			Source: "flatpak",
+		},
+		SocketCandidate{
+			Path:   path.Join(runtimeDir, utils.SocketFileName),
+			Source: "$" + utils.LinuxEnvXdgRuntimeDir,
+		},
+		SocketCandidate{
+			Path:   path.Join(utils.GetEnvWithDefault(utils.LinuxEnvTmpDir, utils.LinuxEnvTmpDirDefault), utils.SocketFileName),
+			Source: "$" + utils.LinuxEnvTmpDir,
+		},
+	)
+}
+
+// isSocket checks the file mode of a socket candidate - Linux version
+func isSocket(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSocket != 0
+}
+
+// connect implements the os specific socket connection action - Linux version
+// The socket has to be owned by the current user and the peer process has to run as the current user,
+// otherwise anyone able to create the socket file could impersonate keepassxc.
+func connect(ctx context.Context, socketPath string) (net.Conn, error) {
+	info, err := os.Stat(socketPath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	lookupPaths := []string{
-		path.Join(userHome, utils.LinuxSnapCommonSubdir),
-		utils.GetEnvWithDefault(utils.LinuxEnvXdgRuntimeDir, fmt.Sprintf("/run/user/%d/", os.Getuid())),
-		utils.GetEnvWithDefault(utils.LinuxEnvTmpDir, utils.LinuxEnvTmpDirDefault),
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+		return nil, errors.Join(fmt.Errorf("socket %s is not owned by the current user", socketPath),
+			utils.ErrKeepassxcPeerVerificationFailed)
 	}
 
-	var filename string
-	for _, base := range lookupPaths {
-		filename = path.Join(base, utils.SocketFileName)
-		if _, err := os.Stat(filename); err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				continue
-			}
-			return "", fmt.Errorf("%w, error on file %s: %w", utils.ErrKeepassxcSocketNotFound, filename, err)
-		}
-		break
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, err
 	}
-
-	if filename == "" {
-		return "", utils.ErrKeepassxcSocketNotFound
+	cred, err := peerCredentials(conn)
+	if err == nil && int(cred.Uid) != os.Getuid() {
+		err = errors.Join(fmt.Errorf("peer process %d of socket %s runs as user %d", cred.Pid, socketPath, cred.Uid),
+			utils.ErrKeepassxcPeerVerificationFailed)
 	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
 
-	return filename, nil
+// peerCredentials returns the credentials of the peer process of the unix socket connection via SO_PEERCRED.
+func peerCredentials(conn net.Conn) (*syscall.Ucred, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, errors.Join(fmt.Errorf("connection to %s is no unix socket", conn.RemoteAddr()),
+			utils.ErrKeepassxcPeerVerificationFailed)
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, errors.Join(err, utils.ErrKeepassxcPeerVerificationFailed)
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, errors.Join(err, utils.ErrKeepassxcPeerVerificationFailed)
+	}
+	if credErr != nil {
+		return nil, errors.Join(credErr, utils.ErrKeepassxcPeerVerificationFailed)
+	}
+	return cred, nil
 }
 
-// connect implements the os specific socket connection action - Linux version
-func connect(socketPath string) (net.Conn, error) {
-	return net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+// verifyPeerExecutable checks the executable of the peer process of the connection - Linux version
+func verifyPeerExecutable(conn net.Conn, executable string) error {
+	cred, err := peerCredentials(conn)
+	if err != nil {
+		return err
+	}
+	peerExecutable, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", cred.Pid))
+	if err != nil {
+		return errors.Join(err, utils.ErrKeepassxcPeerVerificationFailed)
+	}
+	if filepath.Clean(peerExecutable) != filepath.Clean(executable) {
+		return errors.Join(fmt.Errorf("peer process %d is %s, expected %s", cred.Pid, peerExecutable, executable),
+			utils.ErrKeepassxcPeerVerificationFailed)
+	}
+	return nil
 }
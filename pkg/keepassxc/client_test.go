@@ -0,0 +1,478 @@
+package keepassxc_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kevinburke/nacl"
+	"github.com/kevinburke/nacl/box"
+	"github.com/kevinburke/nacl/scalarmult"
+
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/keepassxc/keepassxctest"
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+// newTestClient starts a fake keepassxc and connects an associated client to it.
+// Both are closed at the end of the test.
+func newTestClient(t *testing.T, options ...keepassxctest.ServerOption) (*keepassxc.Client, *keepassxctest.Server) {
+	t.Helper()
+	server, err := keepassxctest.NewServer(options...)
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	client, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptSocketPath(server.SocketPath))
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	return client, server
+}
+
+func TestGetLoginsLargeResponse(t *testing.T) {
+	client, server := newTestClient(t)
+	password := strings.Repeat("p", 1<<20)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		server.AddLogin("https://large.example.com", keepassxc.Entry{Name: name, Password: keepassxc.Password(password)})
+	}
+
+	// the encrypted and base64 encoded reply exceeds 6 MiB and arrives in many reads
+	entries, err := client.GetLogins("https://large.example.com")
+	if err != nil {
+		t.Fatalf("GetLogins: %s", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	for _, entry := range entries {
+		if string(entry.Password) != password {
+			t.Fatalf("entry %s has a password of %d bytes, want %d", entry.Name, len(entry.Password), len(password))
+		}
+	}
+
+	// the connection is still usable after the large reply
+	if _, err = client.GeneratePassword(); err != nil {
+		t.Fatalf("GeneratePassword after the large reply: %s", err)
+	}
+}
+
+func TestGetLoginsConcurrent(t *testing.T) {
+	client, server := newTestClient(t)
+	var urls []string
+	for i := 0; i < 8; i++ {
+		url := fmt.Sprintf("https://host%d.example.com", i)
+		server.AddLogin(url, keepassxc.Entry{Name: url, Login: fmt.Sprintf("user%d", i)})
+		urls = append(urls, url)
+	}
+	const empty = "https://empty.example.com"
+
+	// run with -race, the goroutines share the session, its dispatcher and the nonce handling
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(url string) {
+			defer wg.Done()
+			for n := 0; n < 5; n++ {
+				entries, err := client.GetLogins(url)
+				if err == nil && (len(entries) != 1 || entries[0].Name != url) {
+					err = fmt.Errorf("GetLogins(%s) returned %d entries", url, len(entries))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(urls[i])
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 5; n++ {
+				results, err := client.GetLoginsMulti(append([]string{empty}, urls...)...)
+				if err == nil {
+					err = checkMultiResults(results, urls, empty)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if _, err := client.GetLogins(empty); !errors.Is(err, utils.ErrKeepassxcNoLoginsFound) {
+		t.Fatalf("GetLogins(%s) = %v, want %v", empty, err, utils.ErrKeepassxcNoLoginsFound)
+	}
+}
+
+// checkMultiResults checks the result of GetLoginsMulti, the url without logins maps to empty Entries.
+func checkMultiResults(results map[string]keepassxc.Entries, urls []string, empty string) error {
+	if len(results) != len(urls)+1 {
+		return fmt.Errorf("GetLoginsMulti returned %d urls, want %d", len(results), len(urls)+1)
+	}
+	if entries, ok := results[empty]; !ok || entries == nil || len(entries) != 0 {
+		return fmt.Errorf("GetLoginsMulti returned %v for %s, want empty Entries", entries, empty)
+	}
+	for _, url := range urls {
+		if entries := results[url]; len(entries) != 1 || entries[0].Name != url {
+			return fmt.Errorf("GetLoginsMulti returned %d entries for %s, want 1", len(entries), url)
+		}
+	}
+	return nil
+}
+
+func TestKeyExchangeInvalidPublicKey(t *testing.T) {
+	for _, publicKey := range []string{
+		"",
+		"not base64",
+		base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		base64.StdEncoding.EncodeToString(make([]byte, 33)),
+	} {
+		clientConn, serverConn := net.Pipe()
+		go func() {
+			defer serverConn.Close()
+			var req keepassxc.Envelope
+			if err := json.NewDecoder(serverConn).Decode(&req); err != nil {
+				return
+			}
+			json.NewEncoder(serverConn).Encode(&keepassxc.Envelope{
+				Action:    req.Action,
+				PublicKey: publicKey,
+				Nonce:     utils.NaclNonceToB64(utils.IncrementNonce(utils.B64ToNaclNonce(req.Nonce))),
+				Success:   "true",
+			})
+		}()
+
+		_, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptConn(clientConn), keepassxc.OptSkipAssociation())
+		if !errors.Is(err, utils.ErrKeepassxcInvalidResponse) || !errors.Is(err, utils.ErrKeepassxcKeyExchangeFailed) {
+			t.Errorf("NewClient with public key %q = %v, want %v", publicKey, err, utils.ErrKeepassxcKeyExchangeFailed)
+		}
+	}
+}
+
+func TestSetLoginAssociationId(t *testing.T) {
+	client, server := newTestClient(t, keepassxctest.OptAssociationName("current"))
+
+	err := client.SetLogin(keepassxc.LoginData{Url: "https://new.example.com", Login: "user", Password: "secret"})
+	if err != nil {
+		t.Fatalf("SetLogin: %s", err)
+	}
+	logins := server.Logins()
+	if len(logins) != 1 || logins[0].AssociationId != "current" {
+		t.Fatalf("logins = %+v, want one login set with association id current", logins)
+	}
+}
+
+func TestDatabasePinning(t *testing.T) {
+	pinnedServer, err := keepassxctest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	defer pinnedServer.Close()
+	otherServer, err := keepassxctest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	defer otherServer.Close()
+
+	profile := keepassxctest.NewProfile("", nil)
+	var warnings []error
+	connect := func(server *keepassxctest.Server, policy keepassxc.DatabasePinning) error {
+		client, err := keepassxc.NewClient(profile, keepassxc.OptSocketPath(server.SocketPath),
+			keepassxc.OptDatabasePinning(policy, func(err error) { warnings = append(warnings, err) }))
+		if err == nil {
+			client.Disconnect()
+		}
+		return err
+	}
+
+	// the first database is pinned
+	if err = connect(pinnedServer, keepassxc.DatabasePinningEnforce); err != nil {
+		t.Fatalf("connect to the first database: %s", err)
+	}
+	if pinned := profile.GetPinnedDatabaseHashes(); len(pinned) != 1 || pinned[0] != pinnedServer.DatabaseHash() {
+		t.Fatalf("pinned = %v, want [%s]", pinned, pinnedServer.DatabaseHash())
+	}
+
+	// warn keeps the association of the other database, but does not pin it
+	for i := 1; i <= 2; i++ {
+		if err = connect(otherServer, keepassxc.DatabasePinningWarn); err != nil {
+			t.Fatalf("connect %d with warn: %s", i, err)
+		}
+		if len(warnings) != i || !errors.Is(warnings[i-1], utils.ErrKeepassxcDatabaseNotPinned) {
+			t.Fatalf("warnings after connect %d with warn = %v, want %d", i, warnings, i)
+		}
+	}
+	if _, key := profile.GetDatabaseAssoc(otherServer.DatabaseHash()); key == nil {
+		t.Fatalf("the other database is not associated")
+	}
+	if names := otherServer.Associations(); len(names) != 1 {
+		t.Fatalf("associations of the other database = %v, want a single association", names)
+	}
+
+	if err = connect(otherServer, keepassxc.DatabasePinningEnforce); !errors.Is(err, utils.ErrKeepassxcDatabaseNotPinned) {
+		t.Fatalf("connect with enforce = %v, want %v", err, utils.ErrKeepassxcDatabaseNotPinned)
+	}
+	if err = connect(pinnedServer, keepassxc.DatabasePinningEnforce); err != nil {
+		t.Fatalf("connect to the pinned database: %s", err)
+	}
+}
+
+func TestDatabasePinningExistingAssociations(t *testing.T) {
+	server, err := keepassxctest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	defer server.Close()
+	key := nacl.NewKey()
+	server.Associate("existing", key)
+	profile := keepassxctest.NewProfile("", nil)
+	profile.SetDatabaseAssoc(server.DatabaseHash(), "existing", key)
+	profile.SetDatabaseAssoc("other", "other", nacl.NewKey())
+
+	client, err := keepassxc.NewClient(profile, keepassxc.OptSocketPath(server.SocketPath),
+		keepassxc.OptDatabasePinning(keepassxc.DatabasePinningEnforce, nil))
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	client.Disconnect()
+	if pinned := profile.GetPinnedDatabaseHashes(); len(pinned) != 1 || pinned[0] != server.DatabaseHash() {
+		t.Fatalf("pinned = %v, want only the opened database %s", pinned, server.DatabaseHash())
+	}
+}
+
+func TestDatabasePinningGetLogins(t *testing.T) {
+	for _, test := range []struct {
+		policy keepassxc.DatabasePinning
+		found  bool
+	}{
+		{keepassxc.DatabasePinningOff, true},
+		{keepassxc.DatabasePinningWarn, false},
+		{keepassxc.DatabasePinningEnforce, false},
+	} {
+		server, err := keepassxctest.NewServer()
+		if err != nil {
+			t.Fatalf("NewServer: %s", err)
+		}
+		server.AddLogin("https://example.com", keepassxc.Entry{Name: "test"})
+		pinnedKey, otherKey := nacl.NewKey(), nacl.NewKey()
+		server.Associate("pinned", pinnedKey)
+		profile := keepassxctest.NewProfile("", nil)
+		profile.SetDatabaseAssoc(server.DatabaseHash(), "pinned", pinnedKey)
+		profile.SetPinnedDatabaseHashes([]string{server.DatabaseHash()})
+
+		client, err := keepassxc.NewClient(profile, keepassxc.OptSocketPath(server.SocketPath),
+			keepassxc.OptDatabasePinning(test.policy, nil))
+		if err != nil {
+			t.Fatalf("%s: NewClient: %s", test.policy, err)
+		}
+		// the fake has a single database, so the entries of another opened database are simulated:
+		// only the key of the unpinned database is valid after the connection
+		profile.SetDatabaseAssoc("other", "other", otherKey)
+		server.Associate("other", otherKey)
+		server.Associate("pinned", nacl.NewKey())
+
+		entries, err := client.GetLogins("https://example.com")
+		if test.found && (err != nil || len(entries) != 1) {
+			t.Errorf("%s: GetLogins = %d entries, %v, want the entry of the unpinned database", test.policy, len(entries), err)
+		}
+		if !test.found && !errors.Is(err, utils.ErrKeepassxcAssocFailed) {
+			t.Errorf("%s: GetLogins = %d entries, %v, want %v", test.policy, len(entries), err, utils.ErrKeepassxcAssocFailed)
+		}
+		client.Disconnect()
+		server.Close()
+	}
+}
+
+func TestNewClientFailedAssociationClosesConnection(t *testing.T) {
+	server, err := keepassxctest.NewServer(keepassxctest.OptDenyAssociate())
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	defer server.Close()
+
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		// relay the connection to the fake, to see when the client closes it
+		socket, err := net.Dial("unix", server.SocketPath)
+		if err != nil {
+			serverConn.Close()
+			return
+		}
+		defer socket.Close()
+		go io.Copy(serverConn, socket)
+		io.Copy(socket, serverConn)
+	}()
+
+	client, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptConn(clientConn))
+	if !errors.Is(err, utils.ErrKeepassxcActionCancelledOrDenied) || client != nil {
+		t.Fatalf("NewClient = %v, %v, want no client and %v", client, err, utils.ErrKeepassxcActionCancelledOrDenied)
+	}
+	if _, err = clientConn.Write([]byte("{}")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("write to the connection = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestLockDatabaseWithoutAssociation(t *testing.T) {
+	for _, locked := range []bool{false, true} {
+		var options []keepassxctest.ServerOption
+		if locked {
+			options = append(options, keepassxctest.OptLocked())
+		}
+		server, err := keepassxctest.NewServer(options...)
+		if err != nil {
+			t.Fatalf("NewServer: %s", err)
+		}
+		client, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil),
+			keepassxc.OptSocketPath(server.SocketPath), keepassxc.OptSkipAssociation())
+		if err != nil {
+			t.Fatalf("locked %t: NewClient: %s", locked, err)
+		}
+		if err = client.LockDatabase(); err != nil {
+			t.Errorf("locked %t: LockDatabase: %s", locked, err)
+		}
+		if !server.Locked() || len(server.Associations()) != 0 {
+			t.Errorf("locked %t: database locked %t with associations %v, want locked without associations",
+				locked, server.Locked(), server.Associations())
+		}
+		client.Disconnect()
+		server.Close()
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	server, err := keepassxctest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	defer server.Close()
+	client, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptSocketPath(server.SocketPath),
+		keepassxc.OptReconnect(keepassxc.ReconnectPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	defer client.Disconnect()
+	server.AddLogin("https://example.com", keepassxc.Entry{Name: "example", Uuid: "0123456789abcdef0123456789abcdef"})
+
+	// get-logins is idempotent, it is retried on the new connection
+	server.DropConnections()
+	entries, err := client.GetLogins("https://example.com")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("GetLogins after the connection was dropped = %d entries, %v, want 1 entry", len(entries), err)
+	}
+
+	// delete-entry may have been executed already, so it fails instead of being sent again
+	server.DropConnections()
+	if err = client.DeleteEntry("0123456789abcdef0123456789abcdef"); !errors.Is(err, utils.ErrKeepassxcConnectionClosed) {
+		t.Fatalf("DeleteEntry after the connection was dropped = %v, want %v", err, utils.ErrKeepassxcConnectionClosed)
+	}
+	if logins := server.Logins(); len(logins) != 1 {
+		t.Fatalf("logins after the failed DeleteEntry = %d, want 1", len(logins))
+	}
+
+	// the client reconnected anyway, so the next request succeeds
+	if err = client.DeleteEntry("0123456789abcdef0123456789abcdef"); err != nil {
+		t.Fatalf("DeleteEntry on the new connection: %s", err)
+	}
+	if logins := server.Logins(); len(logins) != 0 || len(server.Associations()) != 1 {
+		t.Fatalf("%d logins and associations %v after DeleteEntry, want none and a single association",
+			len(server.Logins()), server.Associations())
+	}
+}
+
+func TestNonceMismatch(t *testing.T) {
+	for _, test := range []struct {
+		name                   string
+		outerNonce, innerNonce func(respNonce nacl.Nonce) nacl.Nonce
+	}{
+		{"outer", func(nacl.Nonce) nacl.Nonce { return nacl.NewNonce() }, func(respNonce nacl.Nonce) nacl.Nonce { return respNonce }},
+		{"inner", func(respNonce nacl.Nonce) nacl.Nonce { return respNonce }, func(nacl.Nonce) nacl.Nonce { return nacl.NewNonce() }},
+	} {
+		clientConn, serverConn := net.Pipe()
+		go func() {
+			defer serverConn.Close()
+			dec, enc := json.NewDecoder(serverConn), json.NewEncoder(serverConn)
+			var req keepassxc.Envelope
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			privateKey := nacl.NewKey()
+			sharedKey := box.Precompute(utils.B64ToNaclKey(req.PublicKey), privateKey)
+			enc.Encode(&keepassxc.Envelope{
+				Action:    req.Action,
+				PublicKey: utils.NaclKeyToB64(scalarmult.Base(privateKey)),
+				Nonce:     utils.NaclNonceToB64(utils.IncrementNonce(utils.B64ToNaclNonce(req.Nonce))),
+				Success:   "true",
+			})
+
+			// answer the first encrypted request with the tampered nonces
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			respNonce := utils.IncrementNonce(utils.B64ToNaclNonce(req.Nonce))
+			outerNonce := test.outerNonce(respNonce)
+			data, _ := json.Marshal(map[string]string{
+				"nonce":   utils.NaclNonceToB64(test.innerNonce(respNonce)),
+				"success": "true",
+			})
+			enc.Encode(&keepassxc.Envelope{
+				Action:  req.Action,
+				Message: base64.StdEncoding.EncodeToString(box.SealAfterPrecomputation(nil, data, outerNonce, sharedKey)),
+				Nonce:   utils.NaclNonceToB64(outerNonce),
+			})
+			io.Copy(io.Discard, serverConn)
+		}()
+
+		client, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptConn(clientConn), keepassxc.OptSkipAssociation())
+		if err != nil {
+			t.Fatalf("%s: NewClient: %s", test.name, err)
+		}
+		if _, err = client.GeneratePassword(); !errors.Is(err, utils.ErrKeepassxcNonceMismatch) {
+			t.Errorf("%s: GeneratePassword = %v, want %v", test.name, err, utils.ErrKeepassxcNonceMismatch)
+		}
+		client.Disconnect()
+	}
+}
+
+func TestWaitForUnlock(t *testing.T) {
+	server, err := keepassxctest.NewServer(keepassxctest.OptLocked())
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	defer server.Close()
+	go func() {
+		// simulate the user unlocking the database in the dialog
+		for server.UnlockRequests() == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		server.Unlock()
+	}()
+
+	client, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptSocketPath(server.SocketPath),
+		keepassxc.OptWaitForUnlock(5*time.Second))
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	defer client.Disconnect()
+	if requests := server.UnlockRequests(); requests != 1 {
+		t.Fatalf("unlock requests = %d, want 1", requests)
+	}
+	if server.Locked() || len(server.Associations()) != 1 {
+		t.Fatalf("database locked %t with associations %v, want unlocked with a single association",
+			server.Locked(), server.Associations())
+	}
+}
@@ -4,6 +4,8 @@
 package keepassxc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"keepassxc-http-tools-go/pkg/utils"
 	"net"
@@ -12,12 +14,30 @@ import (
 	"github.com/Microsoft/go-winio"
 )
 
-// SocketPath tries to find the path to the socket of the keepassxc http api - Windows version
-func SocketPath() (string, error) {
-	return fmt.Sprintf(`\\.\pipe\%s_%s`, utils.SocketFileName, os.Getenv(utils.WindowsEnvVarUsername)), nil
+// socketCandidates returns the possible locations of the named pipe of the keepassxc http api - Windows version
+// The named pipe is not checked as a file, only connecting shows whether it exists, see DiscoverSockets.
+func socketCandidates() []SocketCandidate {
+	return []SocketCandidate{{
+		Path:   fmt.Sprintf(`\\.\pipe\%s_%s`, utils.SocketFileName, os.Getenv(utils.WindowsEnvVarUsername)),
+		Source: "$" + utils.WindowsEnvVarUsername,
+		Status: SocketFound,
+	}}
+}
+
+// isSocket checks the file mode of a socket candidate - Windows version
+// Named pipes have no distinct file mode, so every existing candidate is accepted.
+func isSocket(info os.FileInfo) bool {
+	return true
 }
 
 // connect implements the os specific socket connection action - Windows version
-func connect(socketPath string) (net.Conn, error) {
-	return winio.DialPipe(socketPath, nil)
+func connect(ctx context.Context, socketPath string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, socketPath)
+}
+
+// verifyPeerExecutable checks the executable of the peer process of the connection - Windows version
+// This is not supported, so it always fails.
+func verifyPeerExecutable(conn net.Conn, executable string) error {
+	return errors.Join(errors.New("peer executable verification is only supported on linux"),
+		utils.ErrKeepassxcPeerVerificationFailed)
 }
@@ -0,0 +1,148 @@
+package keepassxc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Socket discovery implementation
+*/
+
+// SocketStatus represents the result of checking a SocketCandidate.
+type SocketStatus string
+
+const (
+	// The candidate does not exist.
+	SocketMissing SocketStatus = "missing"
+	// The candidate can not be accessed.
+	SocketPermissionDenied SocketStatus = "permission denied"
+	// The candidate exists, but is no socket.
+	SocketNotASocket SocketStatus = "not a socket"
+	// The candidate could not be checked, see SocketCandidate.Error.
+	SocketError SocketStatus = "error"
+	// The candidate is a socket, it was not tried to connect.
+	SocketFound SocketStatus = "found"
+	// The candidate is a socket, but connecting failed, e.g. a stale socket of a crashed keepassxc.
+	SocketUnreachable SocketStatus = "unreachable"
+	// The candidate is a socket and connecting succeeded.
+	SocketConnectable SocketStatus = "connectable"
+)
+
+// SocketCandidate represents a possible location of the socket of the keepassxc http api.
+type SocketCandidate struct {
+	// The path of the socket (named pipe on windows).
+	Path string `json:"path"`
+	// Where the candidate comes from, e.g. "flatpak" or the override environment variable.
+	Source string `json:"source"`
+	// The result of the check.
+	Status SocketStatus `json:"status"`
+	// The error message of the check, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// String returns a human-readable description of the candidate.
+func (c SocketCandidate) String() string {
+	desc := fmt.Sprintf("%s (%s): %s", c.Path, c.Source, c.Status)
+	if c.Error != "" {
+		desc += ", " + c.Error
+	}
+	return desc
+}
+
+// SocketDiscoveryError is returned by SocketPath, if no candidate is a socket.
+// It works with errors.Is() for utils.ErrKeepassxcSocketNotFound.
+type SocketDiscoveryError struct {
+	// All checked candidates.
+	Candidates []SocketCandidate
+}
+
+// Error returns the explanation of all checked candidates.
+func (e *SocketDiscoveryError) Error() string {
+	lines := make([]string, 0, len(e.Candidates)+1)
+	lines = append(lines, fmt.Sprintf("keepassxc socket not found (set $%s to override), checked:", utils.EnvSocketPath))
+	for _, candidate := range e.Candidates {
+		lines = append(lines, "  "+candidate.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns utils.ErrKeepassxcSocketNotFound.
+func (e *SocketDiscoveryError) Unwrap() error {
+	return utils.ErrKeepassxcSocketNotFound
+}
+
+// SocketPath tries to find the path to the socket of the keepassxc http api.
+// The first candidate that is a socket is returned, see SocketCandidates.
+// If there is none, the error is a *SocketDiscoveryError explaining all candidates.
+func SocketPath() (string, error) {
+	candidates := SocketCandidates()
+	for _, candidate := range candidates {
+		if candidate.Status == SocketFound {
+			return candidate.Path, nil
+		}
+	}
+	return "", &SocketDiscoveryError{Candidates: candidates}
+}
+
+// SocketCandidates returns all possible locations of the socket in order of preference, each with its status.
+// If the environment variable utils.EnvSocketPath is set, it is the only candidate.
+// The candidates are not connected to, so the status is SocketFound at best, see DiscoverSockets.
+func SocketCandidates() []SocketCandidate {
+	var candidates []SocketCandidate
+	if path, ok := os.LookupEnv(utils.EnvSocketPath); ok && path != "" {
+		candidates = []SocketCandidate{{Path: path, Source: "$" + utils.EnvSocketPath}}
+	} else {
+		candidates = socketCandidates()
+	}
+	for i := range candidates {
+		if candidates[i].Status == "" {
+			checkSocketCandidate(&candidates[i])
+		}
+	}
+	return candidates
+}
+
+// DiscoverSockets returns all possible locations of the socket like SocketCandidates,
+// but additionally tries to connect to each found socket.
+func DiscoverSockets(ctx context.Context) []SocketCandidate {
+	candidates := SocketCandidates()
+	for i := range candidates {
+		if candidates[i].Status != SocketFound {
+			continue
+		}
+		socket, err := connect(ctx, candidates[i].Path)
+		if err != nil {
+			candidates[i].Status = SocketUnreachable
+			candidates[i].Error = err.Error()
+			continue
+		}
+		socket.Close()
+		candidates[i].Status = SocketConnectable
+	}
+	return candidates
+}
+
+// checkSocketCandidate sets the status of the candidate according to its file.
+func checkSocketCandidate(candidate *SocketCandidate) {
+	info, err := os.Stat(candidate.Path)
+	switch {
+	case err == nil && isSocket(info):
+		candidate.Status = SocketFound
+	case err == nil:
+		candidate.Status = SocketNotASocket
+	case errors.Is(err, os.ErrNotExist):
+		candidate.Status = SocketMissing
+	case errors.Is(err, os.ErrPermission):
+		candidate.Status = SocketPermissionDenied
+		candidate.Error = err.Error()
+	default:
+		candidate.Status = SocketError
+		candidate.Error = err.Error()
+	}
+}
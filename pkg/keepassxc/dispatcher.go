@@ -0,0 +1,190 @@
+package keepassxc
+
+import (
+	"errors"
+	"sync"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Dispatcher implementation
+*/
+
+// eventBufferSize is the number of unsolicited messages buffered for Client.Events().
+const eventBufferSize = 16
+
+// Event represents an unsolicited message pushed by the api, e.g. "database-locked".
+type Event struct {
+	// The action of the message, see utils.ActionDatabaseLocked and utils.ActionDatabaseUnlocked.
+	Action string
+	// The whole message as received from the api.
+	Envelope *Envelope
+}
+
+// pendingRequest represents a request waiting for its response from the reader goroutine.
+type pendingRequest struct {
+	// The action of the request, responses are correlated by it.
+	action string
+	// The nonce the response is expected to carry (the incremented request nonce).
+	nonce string
+	resp  *Envelope
+	err   error
+	done  chan struct{}
+}
+
+// resolve hands the result over to the waiting request.
+func (p *pendingRequest) resolve(resp *Envelope, err error) {
+	p.resp, p.err = resp, err
+	close(p.done)
+}
+
+// dispatcher reads all messages from the socket and routes them to the waiting requests,
+// or to the events channel if nobody asked for them.
+type dispatcher struct {
+	mu      sync.Mutex
+	pending map[string][]*pendingRequest
+	err     error
+	events  chan Event
+	done    chan struct{}
+	// channels closed by the next unsolicited message of their action, see watch
+	watchers map[string][]chan struct{}
+}
+
+// newDispatcher creates a dispatcher and starts its reader goroutine.
+// Unsolicited messages are published to the given events channel.
+func newDispatcher(read func() (*Envelope, error), events chan Event) *dispatcher {
+	d := &dispatcher{
+		pending:  make(map[string][]*pendingRequest),
+		watchers: make(map[string][]chan struct{}),
+		events:   events,
+		done:     make(chan struct{}),
+	}
+	go d.readLoop(read)
+	return d
+}
+
+// readLoop is the reader goroutine, it runs until reading from the socket fails.
+func (d *dispatcher) readLoop(read func() (*Envelope, error)) {
+	defer close(d.done)
+	for {
+		resp, err := read()
+		if err != nil {
+			d.fail(err)
+			return
+		}
+		d.dispatch(resp)
+	}
+}
+
+// register adds a request that waits for a response to the given action.
+// It has to be called before the request is written to the socket.
+func (d *dispatcher) register(action, nonce string) (*pendingRequest, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	req := &pendingRequest{action: action, nonce: nonce, done: make(chan struct{})}
+	d.pending[action] = append(d.pending[action], req)
+	return req, nil
+}
+
+// unregister removes a request, e.g. because sending it failed.
+func (d *dispatcher) unregister(req *pendingRequest) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queue := d.pending[req.action]
+	for i, p := range queue {
+		if p == req {
+			d.pending[req.action] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// watch returns a channel, that is closed by the next unsolicited message of the action,
+// e.g. utils.ActionDatabaseUnlocked. The message is published as Event nevertheless.
+// The channel has to be watched before the message can be triggered, it is never closed if the reader stops.
+// The returned stop function removes the watcher, if it is not needed anymore.
+func (d *dispatcher) watch(action string) (<-chan struct{}, func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	watcher := make(chan struct{})
+	d.watchers[action] = append(d.watchers[action], watcher)
+	return watcher, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for i, w := range d.watchers[action] {
+			if w == watcher {
+				d.watchers[action] = append(d.watchers[action][:i:i], d.watchers[action][i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// dispatch routes a single message.
+// Responses are correlated to their request by action and nonce.
+// Error responses of the api carry no nonce, they are handed to the oldest request of that action.
+// Everything else is published as an Event.
+func (d *dispatcher) dispatch(resp *Envelope) {
+	action, nonce := resp.Action, resp.Nonce
+
+	d.mu.Lock()
+	var req *pendingRequest
+	if action != utils.ActionDatabaseLocked && action != utils.ActionDatabaseUnlocked {
+		req = d.take(action, nonce)
+	}
+	d.mu.Unlock()
+
+	if req != nil {
+		req.resolve(resp, nil)
+		return
+	}
+	d.mu.Lock()
+	for _, watcher := range d.watchers[action] {
+		close(watcher)
+	}
+	delete(d.watchers, action)
+	d.mu.Unlock()
+	select {
+	case d.events <- Event{Action: action, Envelope: resp}:
+	default:
+		// nobody is listening, drop the event instead of blocking the reader
+	}
+}
+
+// take removes and returns the request matching the given action and nonce.
+// If no request matches the nonce, the oldest request of that action is returned,
+// which will then reject the response because of the nonce mismatch.
+// The caller has to hold the lock.
+func (d *dispatcher) take(action, nonce string) *pendingRequest {
+	queue := d.pending[action]
+	if len(queue) == 0 {
+		return nil
+	}
+	idx := 0
+	for i, p := range queue {
+		if nonce != "" && p.nonce == nonce {
+			idx = i
+			break
+		}
+	}
+	req := queue[idx]
+	d.pending[action] = append(queue[:idx:idx], queue[idx+1:]...)
+	return req
+}
+
+// fail resolves all waiting requests with the given error and rejects any further request.
+func (d *dispatcher) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = errors.Join(err, utils.ErrKeepassxcConnectionClosed)
+	for action, queue := range d.pending {
+		for _, req := range queue {
+			req.resolve(nil, d.err)
+		}
+		delete(d.pending, action)
+	}
+}
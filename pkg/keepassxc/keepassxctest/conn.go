@@ -0,0 +1,229 @@
+package keepassxctest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kevinburke/nacl"
+	"github.com/kevinburke/nacl/box"
+	"github.com/kevinburke/nacl/scalarmult"
+
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+// errorMessages are the default messages of the api error codes, as keepassxc sends them.
+var errorMessages = map[int]string{
+	1:  "Database not opened",
+	4:  "Cannot decrypt message",
+	6:  "Action cancelled or denied",
+	8:  "KeePassXC association failed, try again",
+	9:  "Key change was not successful",
+	10: "Encryption key is not recognized",
+	12: "Incorrect action",
+	13: "Empty message received",
+	14: "No URL provided",
+	15: "No logins found",
+	18: "No valid UUID provided",
+}
+
+// errorResponse represents an error of the api, keepassxc sends the code as string.
+type errorResponse struct {
+	Action    string `json:"action"`
+	ErrorCode string `json:"errorCode"`
+	Error     string `json:"error"`
+}
+
+// conn represents a single client connection incl. its encryption keys.
+type conn struct {
+	server  *Server
+	socket  net.Conn
+	writeMu sync.Mutex
+
+	privateKey nacl.Key
+	publicKey  nacl.Key
+	// the public key of the client, set by change-public-keys
+	peerKey nacl.Key
+	// the precomputed shared key of the box encryption
+	sharedKey nacl.Key
+}
+
+// newConn creates a connection with a new key pair.
+func newConn(server *Server, socket net.Conn) *conn {
+	c := &conn{
+		server:     server,
+		socket:     socket,
+		privateKey: nacl.NewKey(),
+	}
+	c.publicKey = scalarmult.Base(c.privateKey)
+	return c
+}
+
+// serve handles the requests of the connection one by one, until the connection is closed.
+func (c *conn) serve() {
+	defer c.socket.Close()
+	decoder := json.NewDecoder(c.socket)
+	for {
+		var req keepassxc.Envelope
+		if err := decoder.Decode(&req); err != nil {
+			return
+		}
+		if delay := c.server.delay(req.Action); delay > 0 {
+			time.Sleep(delay)
+		}
+		if fault := c.server.takeFault(req.Action); fault != nil {
+			c.writeError(req.Action, fault.Code, fault.Message)
+			continue
+		}
+		c.handle(&req)
+	}
+}
+
+// handle answers a single request.
+func (c *conn) handle(req *keepassxc.Envelope) {
+	if req.Action == utils.ActionChangePublicKeys {
+		c.changePublicKeys(req)
+		return
+	}
+	if c.peerKey == nil {
+		c.writeError(req.Action, 9, "")
+		return
+	}
+
+	nonce, msg, ok := c.decrypt(req)
+	if !ok {
+		c.writeError(req.Action, 4, "")
+		return
+	}
+
+	var resp map[string]interface{}
+	var code int
+	switch req.Action {
+	case utils.ActionAssociate:
+		resp, code = c.server.associate(msg)
+	case utils.ActionTestAssociate:
+		resp, code = c.server.testAssociate(msg)
+	case utils.ActionGetLogins:
+		resp, code = c.server.getLogins(msg)
+	case utils.ActionSetLogin:
+		resp, code = c.server.setLogin(msg)
+	case utils.ActionGeneratePassword:
+		resp, code = c.server.generatePassword()
+	case utils.ActionGetDatabaseHash:
+		resp, code = c.server.databaseHash(req.TriggerUnlock == "true")
+	case utils.ActionGetDatabaseGroups:
+		resp, code = c.server.getDatabaseGroups()
+	case utils.ActionCreateNewGroup:
+		resp, code = c.server.createNewGroup(msg)
+	case utils.ActionGetTotp:
+		resp, code = c.server.getTotp(msg)
+	case utils.ActionDeleteEntry:
+		resp, code = c.server.deleteEntry(msg)
+	case utils.ActionLockDatabase:
+		// keepassxc answers with "database not opened" after locking the database
+		c.server.Lock()
+		code = 1
+	default:
+		code = 12
+	}
+	if code != 0 {
+		c.writeError(req.Action, code, "")
+		return
+	}
+	c.writeEncrypted(req.Action, nonce, resp)
+}
+
+// changePublicKeys answers the key exchange, it is the only unencrypted request.
+func (c *conn) changePublicKeys(req *keepassxc.Envelope) {
+	nonce, err := base64.StdEncoding.DecodeString(req.Nonce)
+	c.peerKey = utils.B64ToNaclKey(req.PublicKey)
+	if req.PublicKey == "" || c.peerKey == nil || err != nil || len(nonce) != nacl.NonceSize {
+		c.peerKey = nil
+		c.writeError(req.Action, 3, "Client public key not received")
+		return
+	}
+	c.sharedKey = box.Precompute(c.peerKey, c.privateKey)
+	c.write(&keepassxc.Envelope{
+		Action:    req.Action,
+		Version:   Version,
+		PublicKey: utils.NaclKeyToB64(c.publicKey),
+		Nonce:     utils.NaclNonceToB64(utils.IncrementNonce(utils.B64ToNaclNonce(req.Nonce))),
+		Success:   "true",
+	})
+}
+
+// decrypt decrypts the message of the request, it returns the nonce of the request and the decrypted message.
+func (c *conn) decrypt(req *keepassxc.Envelope) (nacl.Nonce, map[string]interface{}, bool) {
+	nonce, err := base64.StdEncoding.DecodeString(req.Nonce)
+	if err != nil || len(nonce) != nacl.NonceSize {
+		return nil, nil, false
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(req.Message)
+	if err != nil || len(encrypted) == 0 {
+		return nil, nil, false
+	}
+	reqNonce := utils.B64ToNaclNonce(req.Nonce)
+	data, ok := box.OpenAfterPrecomputation(nil, encrypted, reqNonce, c.sharedKey)
+	if !ok {
+		return nil, nil, false
+	}
+	var msg map[string]interface{}
+	if err = json.Unmarshal(data, &msg); err != nil {
+		return nil, nil, false
+	}
+	return reqNonce, msg, true
+}
+
+// writeEncrypted encrypts the response with the incremented request nonce and writes it.
+// The success flag is "true", unless the response sets it.
+func (c *conn) writeEncrypted(action string, nonce nacl.Nonce, resp map[string]interface{}) {
+	respNonce := utils.IncrementNonce(nonce)
+	if resp == nil {
+		resp = make(map[string]interface{})
+	}
+	resp["version"] = Version
+	if _, ok := resp["success"]; !ok {
+		resp["success"] = "true"
+	}
+	resp["nonce"] = utils.NaclNonceToB64(respNonce)
+	data, err := json.Marshal(resp)
+	if err != nil {
+		c.writeError(action, 7, "")
+		return
+	}
+	c.write(&keepassxc.Envelope{
+		Action:  action,
+		Message: base64.StdEncoding.EncodeToString(box.SealAfterPrecomputation(nil, data, respNonce, c.sharedKey)),
+		Nonce:   utils.NaclNonceToB64(respNonce),
+	})
+}
+
+// writeError writes an error of the api, errors carry no nonce.
+func (c *conn) writeError(action string, code int, message string) {
+	if message == "" {
+		message = errorMessages[code]
+	}
+	c.write(&errorResponse{Action: action, ErrorCode: strconv.Itoa(code), Error: message})
+}
+
+// notify writes an unsolicited notification, e.g. database-locked.
+func (c *conn) notify(action string) {
+	c.write(&keepassxc.Envelope{Action: action})
+}
+
+// write writes a single json message, write errors end the connection on the next read.
+func (c *conn) write(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err = c.socket.Write(data); err != nil {
+		c.socket.Close()
+	}
+}
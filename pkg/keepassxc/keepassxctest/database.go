@@ -0,0 +1,285 @@
+package keepassxctest
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/nacl"
+
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	In-memory database implementation
+	Each action returns the response message or the error code of the api.
+*/
+
+// field returns the string field of a decrypted request message.
+func field(msg map[string]interface{}, name string) string {
+	value, _ := msg[name].(string)
+	return value
+}
+
+// associate adds a new association with the key of the request, as if the user accepted the dialog.
+func (s *Server) associate(msg map[string]interface{}) (map[string]interface{}, int) {
+	idKey := utils.B64ToNaclKey(field(msg, "idKey"))
+	if idKey == nil || field(msg, "key") == "" {
+		return nil, 13
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	if s.denyAssociate {
+		return nil, 6
+	}
+	s.associateCount++
+	name := s.assocName
+	if s.associateCount > 1 {
+		name += strconv.Itoa(s.associateCount)
+	}
+	s.assocs[name] = idKey
+	return map[string]interface{}{"id": name, "hash": s.hash}, 0
+}
+
+// testAssociate checks the association of the request.
+func (s *Server) testAssociate(msg map[string]interface{}) (map[string]interface{}, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	id := field(msg, "id")
+	if !s.isAssociated(id, field(msg, "key")) {
+		return nil, 8
+	}
+	return map[string]interface{}{"id": id, "hash": s.hash}, 0
+}
+
+// isAssociated checks the association name and its base64 encoded key.
+// The caller has to hold the lock.
+func (s *Server) isAssociated(name, b64Key string) bool {
+	key, ok := s.assocs[name]
+	if !ok || key == nil {
+		return false
+	}
+	return b64Key == base64.StdEncoding.EncodeToString(key[:])
+}
+
+// getLogins returns the entries matching the host of the url, if any of the keys is associated.
+func (s *Server) getLogins(msg map[string]interface{}) (map[string]interface{}, int) {
+	url := field(msg, "url")
+	if url == "" {
+		return nil, 14
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	associated := false
+	keys, _ := msg["keys"].([]interface{})
+	for _, k := range keys {
+		if key, ok := k.(map[string]interface{}); ok && s.isAssociated(field(key, "id"), field(key, "key")) {
+			associated = true
+			break
+		}
+	}
+	if !associated {
+		return nil, 8
+	}
+
+	entries := keepassxc.Entries{}
+	for i := range s.logins {
+		if matchesUrl(s.logins[i].Url, url) {
+			entry := s.logins[i].Entry
+			entries = append(entries, &entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, 15
+	}
+	return map[string]interface{}{"count": len(entries), "entries": entries, "hash": s.hash}, 0
+}
+
+// setLogin updates the entry with the uuid of the request, or creates a new one.
+// Like keepassxc, it does not check the association name of the request, it is only recorded.
+func (s *Server) setLogin(msg map[string]interface{}) (map[string]interface{}, int) {
+	url := field(msg, "url")
+	if url == "" {
+		return nil, 14
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+
+	if uuid := field(msg, "uuid"); uuid != "" {
+		for i := range s.logins {
+			if s.logins[i].Entry.Uuid == uuid {
+				s.logins[i].Entry.Login = field(msg, "login")
+				s.logins[i].Entry.Password = keepassxc.Password(field(msg, "password"))
+				s.logins[i].AssociationId = field(msg, "id")
+				return map[string]interface{}{"count": nil, "entries": nil, "error": "success", "hash": s.hash}, 0
+			}
+		}
+		return nil, 18
+	}
+	s.logins = append(s.logins, Login{
+		Url: url,
+		Entry: keepassxc.Entry{
+			Name:     urlHost(url),
+			Login:    field(msg, "login"),
+			Password: keepassxc.Password(field(msg, "password")),
+			Group:    s.entryGroup(field(msg, "group"), field(msg, "groupUuid")).Name,
+			Uuid:     newUuid(),
+		},
+		AssociationId: field(msg, "id"),
+	})
+	return map[string]interface{}{"count": nil, "entries": nil, "error": "success", "hash": s.hash}, 0
+}
+
+// entryGroup returns the group for a new entry of set-login, the caller has to hold the lock.
+// Like keepassxc, the default group is used, unless the group name is set and the group UUID exists.
+func (s *Server) entryGroup(name, uuid string) *keepassxc.Group {
+	if name != "" && uuid != "" {
+		var found *keepassxc.Group
+		keepassxc.Groups{s.root}.Walk(func(group *keepassxc.Group) {
+			if group.Uuid == uuid {
+				found = group
+			}
+		})
+		if found != nil {
+			return found
+		}
+	}
+	return s.ensureGroup(DefaultGroup)
+}
+
+// generatePassword returns a random password.
+func (s *Server) generatePassword() (map[string]interface{}, int) {
+	return map[string]interface{}{"password": base64.RawURLEncoding.EncodeToString(nacl.NewKey()[:24])}, 0
+}
+
+// getTotp returns the totp of the entry with the uuid of the request, it is empty if the entry has none.
+func (s *Server) getTotp(msg map[string]interface{}) (map[string]interface{}, int) {
+	uuid := field(msg, "uuid")
+	if uuid == "" {
+		return nil, 18
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	for i := range s.logins {
+		if s.logins[i].Entry.Uuid == uuid {
+			return map[string]interface{}{"totp": s.logins[i].Entry.Totp}, 0
+		}
+	}
+	return map[string]interface{}{"totp": ""}, 0
+}
+
+// deleteEntry removes the entry with the uuid of the request.
+// Like keepassxc, the success flag is "false", if the entry is not found or the deletion is denied.
+func (s *Server) deleteEntry(msg map[string]interface{}) (map[string]interface{}, int) {
+	uuid := field(msg, "uuid")
+	if uuid == "" {
+		return nil, 18
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	if s.denyDelete {
+		return map[string]interface{}{"success": "false"}, 0
+	}
+	for i := range s.logins {
+		if s.logins[i].Entry.Uuid == uuid {
+			s.logins = append(s.logins[:i], s.logins[i+1:]...)
+			return nil, 0
+		}
+	}
+	return map[string]interface{}{"success": "false"}, 0
+}
+
+// databaseHash returns the hash of the database, if it is unlocked.
+// A locked database counts as unlock request, if triggerUnlock is set.
+func (s *Server) databaseHash(triggerUnlock bool) (map[string]interface{}, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		if triggerUnlock {
+			s.unlockRequests++
+		}
+		return nil, 1
+	}
+	return map[string]interface{}{"hash": s.hash}, 0
+}
+
+// getDatabaseGroups returns a copy of the group tree below the root group.
+func (s *Server) getDatabaseGroups() (map[string]interface{}, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	return map[string]interface{}{
+		"defaultGroup": "",
+		"groups":       map[string]interface{}{"groups": keepassxc.Groups{copyGroup(s.root)}},
+	}, 0
+}
+
+// createNewGroup creates the group with the path of the request incl. missing parent groups.
+// Like keepassxc, an existing group is returned instead of creating a duplicate.
+func (s *Server) createNewGroup(msg map[string]interface{}) (map[string]interface{}, int) {
+	path := strings.Trim(field(msg, "groupName"), "/")
+	if path == "" {
+		return nil, 13
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return nil, 1
+	}
+	group := s.ensureGroup(path)
+	return map[string]interface{}{"name": group.Name, "uuid": group.Uuid}, 0
+}
+
+// ensureGroup returns the group with the path below the root group, missing groups are created.
+// The caller has to hold the lock.
+func (s *Server) ensureGroup(path string) *keepassxc.Group {
+	group := s.root
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		var child *keepassxc.Group
+		for _, existing := range group.Children {
+			if existing.Name == name {
+				child = existing
+				break
+			}
+		}
+		if child == nil {
+			child = &keepassxc.Group{Name: name, Uuid: newUuid(), Children: keepassxc.Groups{}}
+			group.Children = append(group.Children, child)
+		}
+		group = child
+	}
+	return group
+}
+
+// copyGroup returns a deep copy of the group, the response is encoded after releasing the lock.
+func copyGroup(group *keepassxc.Group) *keepassxc.Group {
+	cp := &keepassxc.Group{Name: group.Name, Uuid: group.Uuid, Children: make(keepassxc.Groups, 0, len(group.Children))}
+	for _, child := range group.Children {
+		cp.Children = append(cp.Children, copyGroup(child))
+	}
+	return cp
+}
@@ -0,0 +1,113 @@
+package keepassxctest
+
+import (
+	"sync"
+
+	"github.com/kevinburke/nacl"
+)
+
+// Profile is an in-memory keepassxc.KeepassxcClientProfile, it also implements
+// keepassxc.KeepassxcMultiDatabaseProfile, keepassxc.KeepassxcPinningProfile and keepassxc.KeepassxcClientIdentityProfile.
+// The zero value is a profile without associations.
+type Profile struct {
+	mu        sync.Mutex
+	name      string
+	key       nacl.Key
+	assocs    map[string]profileAssoc
+	pinned    []string
+	clientId  string
+	clientKey nacl.Key
+}
+
+// profileAssoc represents the association of a Profile with a single database.
+type profileAssoc struct {
+	name string
+	key  nacl.Key
+}
+
+// NewProfile creates a Profile with the given (legacy) association, the key may be nil.
+func NewProfile(name string, key nacl.Key) *Profile {
+	return &Profile{name: name, key: key}
+}
+
+// GetAssocName implements keepassxc.KeepassxcClientProfile.
+func (p *Profile) GetAssocName() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.name
+}
+
+// GetAssocKey implements keepassxc.KeepassxcClientProfile.
+func (p *Profile) GetAssocKey() nacl.Key {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.key
+}
+
+// SetAssoc implements keepassxc.KeepassxcClientProfile.
+func (p *Profile) SetAssoc(name string, key nacl.Key) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.name, p.key = name, key
+	return nil
+}
+
+// GetDatabaseHashes implements keepassxc.KeepassxcMultiDatabaseProfile.
+func (p *Profile) GetDatabaseHashes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hashes := make([]string, 0, len(p.assocs))
+	for hash := range p.assocs {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// GetDatabaseAssoc implements keepassxc.KeepassxcMultiDatabaseProfile.
+func (p *Profile) GetDatabaseAssoc(hash string) (string, nacl.Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	assoc := p.assocs[hash]
+	return assoc.name, assoc.key
+}
+
+// SetDatabaseAssoc implements keepassxc.KeepassxcMultiDatabaseProfile.
+func (p *Profile) SetDatabaseAssoc(hash, name string, key nacl.Key) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.assocs == nil {
+		p.assocs = make(map[string]profileAssoc)
+	}
+	p.assocs[hash] = profileAssoc{name: name, key: key}
+	return nil
+}
+
+// GetPinnedDatabaseHashes implements keepassxc.KeepassxcPinningProfile.
+func (p *Profile) GetPinnedDatabaseHashes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.pinned...)
+}
+
+// SetPinnedDatabaseHashes implements keepassxc.KeepassxcPinningProfile.
+func (p *Profile) SetPinnedDatabaseHashes(hashes []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pinned = append([]string(nil), hashes...)
+	return nil
+}
+
+// GetClientIdentity implements keepassxc.KeepassxcClientIdentityProfile.
+func (p *Profile) GetClientIdentity() (string, nacl.Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clientId, p.clientKey
+}
+
+// SetClientIdentity implements keepassxc.KeepassxcClientIdentityProfile.
+func (p *Profile) SetClientIdentity(id string, key nacl.Key) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clientId, p.clientKey = id, key
+	return nil
+}
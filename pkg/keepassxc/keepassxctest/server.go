@@ -0,0 +1,372 @@
+// Package keepassxctest provides an in-process fake of the keepassxc browser api for tests.
+//
+// A Server listens on a unix socket in a temporary directory and implements the protocol
+// (key exchange, encryption, associations, logins, groups and lock notifications) against an in-memory database.
+// Clients connect to it with keepassxc.OptSocketPath(server.SocketPath).
+// On windows the client connects to named pipes only, so the Server is not usable there.
+package keepassxctest
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinburke/nacl"
+
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+// Version is the keepassxc version the Server reports in its responses.
+const Version = "2.7.9"
+
+// DefaultGroup is the group set-login creates entries in without a valid group, as keepassxc does it.
+const DefaultGroup = "KeePassXC-Browser Passwords"
+
+// Fault represents an error the Server returns instead of handling a request, see Server.InjectError.
+type Fault struct {
+	// The error code, see utils.ProtocolError.
+	Code int
+	// The error message, the default message of the code is used if empty.
+	Message string
+}
+
+// Login represents an entry of the in-memory database together with its URL.
+type Login struct {
+	// The URL the entry is returned for by get-logins, only the host is compared.
+	Url string
+	// The entry as returned by get-logins.
+	Entry keepassxc.Entry
+	// The association id of the last set-login request for the entry, empty for entries added by AddLogin.
+	AssociationId string
+}
+
+// Server represents a fake keepassxc instance listening on a unix socket.
+type Server struct {
+	// The path of the unix socket, see keepassxc.OptSocketPath.
+	SocketPath string
+
+	listener net.Listener
+	dir      string
+	wg       sync.WaitGroup
+
+	// mu guards the database state and the connections
+	mu             sync.Mutex
+	hash           string
+	locked         bool
+	denyAssociate  bool
+	denyDelete     bool
+	assocName      string
+	assocs         map[string]nacl.Key
+	logins         []Login
+	root           *keepassxc.Group
+	faults         map[string][]Fault
+	delays         map[string]time.Duration
+	conns          map[*conn]struct{}
+	closed         bool
+	associateCount int
+	unlockRequests int
+}
+
+// ServerOption type represents an option function for NewServer.
+type ServerOption func(*Server) error
+
+// OptDatabaseHash is an option to NewServer.
+// It sets the hash identifying the fake database, a random hash is used by default.
+func OptDatabaseHash(hash string) ServerOption {
+	return func(server *Server) error {
+		if hash == "" {
+			return errors.New("keepassxctest: empty database hash")
+		}
+		server.hash = hash
+		return nil
+	}
+}
+
+// OptLocked is an option to NewServer.
+// The fake database starts locked, see Server.Unlock.
+func OptLocked() ServerOption {
+	return func(server *Server) error {
+		server.locked = true
+		return nil
+	}
+}
+
+// OptAssociationName is an option to NewServer.
+// It sets the name the "user" enters in the association dialog, defaults to "keepassxctest".
+// Further associations get a counter appended.
+func OptAssociationName(name string) ServerOption {
+	return func(server *Server) error {
+		server.assocName = name
+		return nil
+	}
+}
+
+// OptDenyAssociate is an option to NewServer.
+// All association requests are denied, as if the user cancelled the association dialog.
+func OptDenyAssociate() ServerOption {
+	return func(server *Server) error {
+		server.denyAssociate = true
+		return nil
+	}
+}
+
+// OptDenyDelete is an option to NewServer.
+// All delete-entry requests are denied, as if the user cancelled the confirmation dialog.
+func OptDenyDelete() ServerOption {
+	return func(server *Server) error {
+		server.denyDelete = true
+		return nil
+	}
+}
+
+// NewServer creates a fake keepassxc and starts listening on a unix socket in a new temporary directory.
+// The Server has to be closed with Close, which also removes the directory.
+func NewServer(options ...ServerOption) (*Server, error) {
+	server := &Server{
+		hash:      hex.EncodeToString(nacl.NewKey()[:]),
+		assocName: "keepassxctest",
+		assocs:    make(map[string]nacl.Key),
+		root:      &keepassxc.Group{Name: "Root", Uuid: newUuid(), Children: keepassxc.Groups{}},
+		faults:    make(map[string][]Fault),
+		delays:    make(map[string]time.Duration),
+		conns:     make(map[*conn]struct{}),
+	}
+	for _, option := range options {
+		if err := option(server); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if server.dir, err = os.MkdirTemp("", "keepassxctest"); err != nil {
+		return nil, err
+	}
+	server.SocketPath = filepath.Join(server.dir, utils.SocketFileName)
+	if server.listener, err = net.Listen("unix", server.SocketPath); err != nil {
+		os.RemoveAll(server.dir)
+		return nil, err
+	}
+	server.wg.Add(1)
+	go server.serve()
+	return server, nil
+}
+
+// serve accepts connections until the listener is closed.
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		socket, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := newConn(s, socket)
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			socket.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops the Server, closes all connections and removes the socket.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	err := s.listener.Close()
+	s.DropConnections()
+	s.wg.Wait()
+	return errors.Join(err, os.RemoveAll(s.dir))
+}
+
+// DropConnections closes all current connections, e.g. to simulate a keepassxc restart.
+// The Server keeps accepting new connections.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.socket.Close()
+	}
+}
+
+// DatabaseHash returns the hash identifying the fake database.
+func (s *Server) DatabaseHash() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hash
+}
+
+// Associate adds an association, as if a client had associated before.
+func (s *Server) Associate(name string, key nacl.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assocs[name] = key
+}
+
+// Associations returns the names of all associations.
+func (s *Server) Associations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.assocs))
+	for name := range s.assocs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// AddLogin adds an entry to the fake database, that is returned by get-logins for the host of the URL.
+// A UUID is generated, if the entry has none.
+func (s *Server) AddLogin(url string, entry keepassxc.Entry) {
+	if entry.Uuid == "" {
+		entry.Uuid = newUuid()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logins = append(s.logins, Login{Url: url, Entry: entry})
+}
+
+// Logins returns all entries of the fake database, e.g. to check the result of set-login.
+func (s *Server) Logins() []Login {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logins := make([]Login, len(s.logins))
+	copy(logins, s.logins)
+	return logins
+}
+
+// AddGroup adds the group with the given path, e.g. "/scripts/clip", incl. missing parent groups.
+// It returns the UUID of the group, existing groups are kept.
+func (s *Server) AddGroup(path string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ensureGroup(path).Uuid
+}
+
+// Lock locks the fake database and notifies all connected clients.
+func (s *Server) Lock() {
+	s.setLocked(true)
+}
+
+// Unlock unlocks the fake database and notifies all connected clients.
+func (s *Server) Unlock() {
+	s.setLocked(false)
+}
+
+// UnlockRequests returns how often the unlock dialog was triggered by get-databasehash while the database was locked.
+// The fake shows no dialog, call Unlock to simulate the user unlocking the database.
+func (s *Server) UnlockRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unlockRequests
+}
+
+// Locked returns whether the fake database is locked.
+func (s *Server) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locked
+}
+
+// setLocked changes the lock state and broadcasts the notification, if the state changed.
+func (s *Server) setLocked(locked bool) {
+	s.mu.Lock()
+	if s.locked == locked {
+		s.mu.Unlock()
+		return
+	}
+	s.locked = locked
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	action := utils.ActionDatabaseUnlocked
+	if locked {
+		action = utils.ActionDatabaseLocked
+	}
+	for _, c := range conns {
+		c.notify(action)
+	}
+}
+
+// InjectError lets the next request of the action fail with the given error code, see utils.ProtocolError.
+// Multiple injected errors of an action are returned in order. The default message of the code is used,
+// if message is empty.
+func (s *Server) InjectError(action string, code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[action] = append(s.faults[action], Fault{Code: code, Message: message})
+}
+
+// SetDelay delays the responses to all requests of the action, e.g. to test timeouts.
+// A zero duration removes the delay.
+func (s *Server) SetDelay(action string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if delay <= 0 {
+		delete(s.delays, action)
+		return
+	}
+	s.delays[action] = delay
+}
+
+// takeFault removes and returns the next injected error of the action.
+func (s *Server) takeFault(action string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.faults[action]
+	if len(queue) == 0 {
+		return nil
+	}
+	s.faults[action] = queue[1:]
+	return &queue[0]
+}
+
+// delay returns the configured delay of the action.
+func (s *Server) delay(action string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delays[action]
+}
+
+// newUuid returns a random UUID in the format of keepassxc (32 hex characters).
+func newUuid() string {
+	return hex.EncodeToString(nacl.NewNonce()[:16])
+}
+
+// matchesUrl checks whether the login url has the same host as the requested url.
+// Hosts are compared as keepassxc does it without its additional settings.
+func matchesUrl(loginUrl, requestUrl string) bool {
+	return urlHost(loginUrl) == urlHost(requestUrl)
+}
+
+// urlHost returns the host of the url, urls without scheme are treated as host.
+func urlHost(rawUrl string) string {
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "https://" + rawUrl
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,242 @@
+package keepassxctest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"keepassxc-http-tools-go/pkg/keepassxc"
+	"keepassxc-http-tools-go/pkg/keepassxc/keepassxctest"
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+// newServer starts a Server, that is closed at the end of the test.
+func newServer(t *testing.T, options ...keepassxctest.ServerOption) *keepassxctest.Server {
+	t.Helper()
+	server, err := keepassxctest.NewServer(options...)
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// newClient connects a client with the profile to the server, it is disconnected at the end of the test.
+func newClient(t *testing.T, server *keepassxctest.Server, profile *keepassxctest.Profile, options ...keepassxc.ClientOption) *keepassxc.Client {
+	t.Helper()
+	client, err := keepassxc.NewClient(profile, append([]keepassxc.ClientOption{keepassxc.OptSocketPath(server.SocketPath)}, options...)...)
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	return client
+}
+
+func TestKeyExchangeAndAssociate(t *testing.T) {
+	server := newServer(t, keepassxctest.OptAssociationName("test"))
+	profile := keepassxctest.NewProfile("", nil)
+	client := newClient(t, server, profile)
+
+	if names := server.Associations(); len(names) != 1 || names[0] != "test" {
+		t.Fatalf("associations = %v, want [test]", names)
+	}
+	name, key := profile.GetDatabaseAssoc(server.DatabaseHash())
+	if name != "test" || key == nil {
+		t.Fatalf("profile association = %q, %v, want test with a key", name, key)
+	}
+	if err := client.TestAssociate(); err != nil {
+		t.Fatalf("TestAssociate: %s", err)
+	}
+}
+
+func TestTestAssociateReusesAssociation(t *testing.T) {
+	server := newServer(t)
+	profile := keepassxctest.NewProfile("", nil)
+	newClient(t, server, profile).Disconnect()
+	newClient(t, server, profile)
+
+	if names := server.Associations(); len(names) != 1 {
+		t.Fatalf("associations = %v, want a single association", names)
+	}
+}
+
+func TestTestAssociateUnknownKey(t *testing.T) {
+	server := newServer(t)
+	key := utils.B64ToNaclKey("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	profile := keepassxctest.NewProfile("", nil)
+	profile.SetDatabaseAssoc(server.DatabaseHash(), "unknown", key)
+	client := newClient(t, server, profile, keepassxc.OptSkipAssociation())
+
+	if err := client.TestAssociate(); !errors.Is(err, utils.ErrKeepassxcAssocFailed) {
+		t.Fatalf("TestAssociate = %v, want %v", err, utils.ErrKeepassxcAssocFailed)
+	}
+}
+
+func TestDenyAssociate(t *testing.T) {
+	server := newServer(t, keepassxctest.OptDenyAssociate())
+	_, err := keepassxc.NewClient(keepassxctest.NewProfile("", nil), keepassxc.OptSocketPath(server.SocketPath))
+	if !errors.Is(err, utils.ErrKeepassxcActionCancelledOrDenied) {
+		t.Fatalf("NewClient = %v, want %v", err, utils.ErrKeepassxcActionCancelledOrDenied)
+	}
+}
+
+func TestRequestWithoutKeyExchange(t *testing.T) {
+	server := newServer(t)
+	conn, err := net.Dial("unix", server.SocketPath)
+	if err != nil {
+		t.Fatalf("Dial: %s", err)
+	}
+	defer conn.Close()
+	if err = json.NewEncoder(conn).Encode(&keepassxc.Envelope{Action: utils.ActionGetLogins}); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	var resp keepassxc.Envelope
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+	if resp.ErrorCode != 9 || resp.Error != "Key change was not successful" {
+		t.Fatalf("error = %d %q, want 9 %q", resp.ErrorCode, resp.Error, "Key change was not successful")
+	}
+}
+
+func TestInjectError(t *testing.T) {
+	server := newServer(t)
+	client := newClient(t, server, keepassxctest.NewProfile("", nil))
+	server.InjectError(utils.ActionGeneratePassword, 10, "")
+	server.InjectError(utils.ActionGeneratePassword, 19, "custom")
+
+	_, err := client.GeneratePassword()
+	var protocolErr *utils.ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, utils.ErrKeepassxcEncryptionKeyUnrecognized) {
+		t.Fatalf("GeneratePassword = %v, want %v", err, utils.ErrKeepassxcEncryptionKeyUnrecognized)
+	}
+	if protocolErr.Message != "Encryption key is not recognized" {
+		t.Fatalf("message = %q, want the default message of code 10", protocolErr.Message)
+	}
+	_, err = client.GeneratePassword()
+	if !errors.As(err, &protocolErr) || protocolErr.Code != 19 || protocolErr.Message != "custom" {
+		t.Fatalf("GeneratePassword = %v, want code 19 with message custom", err)
+	}
+	if _, err = client.GeneratePassword(); err != nil {
+		t.Fatalf("GeneratePassword after the injected errors: %s", err)
+	}
+}
+
+func TestSetDelay(t *testing.T) {
+	server := newServer(t)
+	client := newClient(t, server, keepassxctest.NewProfile("", nil))
+	server.SetDelay(utils.ActionGeneratePassword, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GeneratePasswordContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GeneratePasswordContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// the server still answers the timed out request first, its response is dropped by the client
+	server.SetDelay(utils.ActionGeneratePassword, 0)
+	if _, err := client.GeneratePassword(); err != nil {
+		t.Fatalf("GeneratePassword: %s", err)
+	}
+}
+
+func TestLockNotifications(t *testing.T) {
+	server := newServer(t)
+	client := newClient(t, server, keepassxctest.NewProfile("", nil))
+
+	for _, step := range []struct {
+		change func()
+		action string
+	}{
+		{server.Lock, utils.ActionDatabaseLocked},
+		{server.Unlock, utils.ActionDatabaseUnlocked},
+	} {
+		step.change()
+		select {
+		case event := <-client.Events():
+			if event.Action != step.action {
+				t.Fatalf("event = %s, want %s", event.Action, step.action)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", step.action)
+		}
+	}
+}
+
+func TestLockedDatabase(t *testing.T) {
+	server := newServer(t)
+	client := newClient(t, server, keepassxctest.NewProfile("", nil))
+	server.Lock()
+
+	if _, err := client.GetDatabaseHash(); !errors.Is(err, utils.ErrKeepassxcDatabaseNotOpened) {
+		t.Fatalf("GetDatabaseHash = %v, want %v", err, utils.ErrKeepassxcDatabaseNotOpened)
+	}
+	server.Unlock()
+	if hash, err := client.GetDatabaseHash(); err != nil || hash != server.DatabaseHash() {
+		t.Fatalf("GetDatabaseHash = %q, %v, want %q", hash, err, server.DatabaseHash())
+	}
+}
+
+func TestGroups(t *testing.T) {
+	server := newServer(t)
+	client := newClient(t, server, keepassxctest.NewProfile("", nil))
+	uuid := server.AddGroup("/work/prod")
+
+	group, err := client.CreateNewGroup("/scripts/clip")
+	if err != nil {
+		t.Fatalf("CreateNewGroup: %s", err)
+	}
+	if group.Name != "clip" || group.Path != "/scripts/clip" {
+		t.Fatalf("created group = %q at %q, want clip at /scripts/clip", group.Name, group.Path)
+	}
+	again, err := client.CreateNewGroup("scripts/clip")
+	if err != nil || again.Uuid != group.Uuid {
+		t.Fatalf("CreateNewGroup of an existing group = %v, %v, want uuid %s", again, err, group.Uuid)
+	}
+
+	groups, err := client.GetDatabaseGroups()
+	if err != nil {
+		t.Fatalf("GetDatabaseGroups: %s", err)
+	}
+	if prod := groups.FindByPath("/work/prod"); prod == nil || prod.Uuid != uuid {
+		t.Fatalf("group /work/prod = %v, want uuid %s", prod, uuid)
+	}
+	if clip := groups.FindByPath("/scripts/clip"); clip == nil || clip.Uuid != group.Uuid {
+		t.Fatalf("group /scripts/clip = %v, want uuid %s", clip, group.Uuid)
+	}
+}
+
+func TestSetLoginGroup(t *testing.T) {
+	server := newServer(t)
+	client := newClient(t, server, keepassxctest.NewProfile("", nil))
+	uuid := server.AddGroup("/scripts/clip")
+
+	for _, test := range []struct {
+		group, groupUuid, want string
+	}{
+		{"", "", keepassxctest.DefaultGroup},
+		{"clip", "", keepassxctest.DefaultGroup},
+		{"", uuid, keepassxctest.DefaultGroup},
+		{"clip", "00000000000000000000000000000000", keepassxctest.DefaultGroup},
+		{"clip", uuid, "clip"},
+	} {
+		url := "https://" + test.group + test.groupUuid + ".example.com"
+		err := client.SetLogin(keepassxc.LoginData{Url: url, Login: "user", Group: test.group, GroupUuid: test.groupUuid})
+		if err != nil {
+			t.Fatalf("SetLogin: %s", err)
+		}
+		group := "<no entry>"
+		for _, login := range server.Logins() {
+			if login.Url == url {
+				group = login.Entry.Group
+			}
+		}
+		if group != test.want {
+			t.Errorf("group %q with uuid %q: entry is in %q, want %q", test.group, test.groupUuid, group, test.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package keepassxc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Database pinning implementation
+*/
+
+// DatabasePinning represents the policy for opened databases that are not pinned.
+// The hashes of the pinned databases are recorded in a KeepassxcPinningProfile, see Client.GetDatabaseHash().
+type DatabasePinning string
+
+const (
+	// Any opened database is used and associated on demand, the default.
+	DatabasePinningOff DatabasePinning = "off"
+	// Databases that are not pinned are associated on demand, but a warning is passed to the warn function.
+	// They are not pinned by their association, so the warning is repeated on each connection,
+	// and their entries are not returned by get-logins.
+	DatabasePinningWarn DatabasePinning = "warn"
+	// Databases that are not pinned are refused with utils.ErrKeepassxcDatabaseNotPinned.
+	DatabasePinningEnforce DatabasePinning = "enforce"
+)
+
+// OptDatabasePinning is an option to NewClient.
+// It sets the policy for opened databases that do not match the hashes pinned in the profile,
+// the profile has to implement KeepassxcPinningProfile for any policy but DatabasePinningOff.
+// If nothing is pinned yet, the opened database is pinned, when it is associated or its association is tested.
+// Only the keys of the pinned databases are sent with get-logins, so other opened databases return no entries.
+// For DatabasePinningWarn the warn function is called with the mismatch error, it may be nil.
+// The policy is checked on connection and on reconnects, so switching the database in keepassxc is detected.
+func OptDatabasePinning(policy DatabasePinning, warn func(err error)) ClientOption {
+	return func(client *Client) error {
+		switch policy {
+		case "", DatabasePinningOff:
+			client.pinning = DatabasePinningOff
+			return nil
+		case DatabasePinningWarn, DatabasePinningEnforce:
+		default:
+			return fmt.Errorf("unknown database pinning %q, use %q, %q or %q",
+				policy, DatabasePinningOff, DatabasePinningWarn, DatabasePinningEnforce)
+		}
+		if _, ok := client.AssocProfile.(KeepassxcPinningProfile); !ok {
+			return errors.New("database pinning needs a KeepassxcPinningProfile")
+		}
+		client.pinning = policy
+		client.pinningWarn = warn
+		return nil
+	}
+}
+
+// checkDatabasePinning is a helper function for ensureAssociation.
+// It applies the pinning policy to the opened database, before its association is used or created.
+func (c *Client) checkDatabasePinning(hash string) error {
+	if c.pinning == "" || c.pinning == DatabasePinningOff {
+		return nil
+	}
+	pinned := c.AssocProfile.(KeepassxcPinningProfile).GetPinnedDatabaseHashes()
+	// nothing is pinned yet, the database is pinned after its association, see pinDatabase
+	if len(pinned) == 0 || slices.Contains(pinned, hash) {
+		return nil
+	}
+	err := errors.Join(fmt.Errorf("opened database %s is not pinned, pinned are: %s", hash, strings.Join(pinned, ", ")),
+		utils.ErrKeepassxcDatabaseNotPinned)
+	if c.pinning == DatabasePinningWarn {
+		if c.pinningWarn != nil {
+			c.pinningWarn(err)
+		}
+		return nil
+	}
+	return err
+}
+
+// pinDatabase is a helper function for ensureAssociation and associate.
+// It pins the associated database, if pinning is enabled and nothing is pinned yet.
+func (c *Client) pinDatabase(hash string) error {
+	if c.pinning == "" || c.pinning == DatabasePinningOff {
+		return nil
+	}
+	profile := c.AssocProfile.(KeepassxcPinningProfile)
+	if len(profile.GetPinnedDatabaseHashes()) > 0 {
+		return nil
+	}
+	return profile.SetPinnedDatabaseHashes([]string{hash})
+}
+
+// pinnedDatabases returns the hashes of the pinned databases, or nil if pinning is disabled or nothing is pinned yet.
+func (c *Client) pinnedDatabases() []string {
+	if c.pinning == "" || c.pinning == DatabasePinningOff {
+		return nil
+	}
+	return c.AssocProfile.(KeepassxcPinningProfile).GetPinnedDatabaseHashes()
+}
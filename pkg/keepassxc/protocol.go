@@ -0,0 +1,432 @@
+package keepassxc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Protocol representation
+	See https://github.com/keepassxreboot/keepassxc-browser/blob/develop/keepassxc-protocol.md
+*/
+
+// Request is implemented by all requests to the api.
+// Encrypted requests are sent as the Message of an Envelope.
+type Request interface {
+	// RequestAction returns the action of the request.
+	RequestAction() string
+}
+
+// Response is implemented by all decrypted responses of the api.
+type Response interface {
+	// Header returns the fields common to all responses.
+	Header() *ResponseHeader
+	// Validate checks the required fields of the response.
+	Validate() error
+}
+
+// ErrorCode represents the error code of the api, it is sent as a string or as a number.
+type ErrorCode int
+
+// UnmarshalJSON parses the error code from a json string or number.
+func (e *ErrorCode) UnmarshalJSON(data []byte) error {
+	var code interface{}
+	if err := json.Unmarshal(data, &code); err != nil {
+		return err
+	}
+	switch v := code.(type) {
+	case nil:
+		*e = 0
+	case float64:
+		*e = ErrorCode(v)
+	case string:
+		value, err := strconv.Atoi(v)
+		if err != nil && v != "" {
+			return fmt.Errorf("invalid error code %q", v)
+		}
+		*e = ErrorCode(value)
+	default:
+		return fmt.Errorf("invalid error code %v", v)
+	}
+	return nil
+}
+
+// Envelope represents the outer json message exchanged with the api.
+// Encrypted requests and responses carry their payload base64 encoded in Message.
+// The api also uses it for unencrypted messages, e.g. change-public-keys and notifications.
+type Envelope struct {
+	// The action of the message, see utils.Action* constants.
+	Action string `json:"action"`
+	// The encrypted payload, see Request and Response.
+	Message string `json:"message,omitempty"`
+	// The nonce of the message, the api answers with the incremented nonce of the request.
+	Nonce string `json:"nonce,omitempty"`
+	// The id of the client, only set in requests.
+	ClientID string `json:"clientID,omitempty"`
+	// The public key of the client or the server, only set for change-public-keys.
+	PublicKey string `json:"publicKey,omitempty"`
+	// "true" to show the unlock dialog of keepassxc, only set for get-databasehash requests.
+	TriggerUnlock string `json:"triggerUnlock,omitempty"`
+	// The keepassxc version, only set in responses.
+	Version string `json:"version,omitempty"`
+	// The success flag, only set in unencrypted responses.
+	Success string `json:"success,omitempty"`
+	// The error message, only set if the api failed.
+	Error string `json:"error,omitempty"`
+	// The error code, only set if the api failed, see utils.ProtocolError.
+	ErrorCode ErrorCode `json:"errorCode,omitempty"`
+}
+
+// RequestAction implements Request for unencrypted requests.
+func (e *Envelope) RequestAction() string {
+	return e.Action
+}
+
+// protocolError returns the error of the api, or nil if the envelope is no error.
+// Some actions answer with an empty error on success.
+func (e *Envelope) protocolError() error {
+	if e.Error == "" && e.ErrorCode == 0 {
+		return nil
+	}
+	return &utils.ProtocolError{Action: e.Action, Code: int(e.ErrorCode), Message: e.Error}
+}
+
+// ResponseHeader contains the fields keepassxc adds to all encrypted responses.
+type ResponseHeader struct {
+	// The keepassxc version.
+	Version string `json:"version"`
+	// The success flag, "true" on success.
+	Success string `json:"success"`
+	// The nonce, it has to match the nonce of the envelope.
+	Nonce string `json:"nonce"`
+}
+
+// Header implements Response.
+func (h *ResponseHeader) Header() *ResponseHeader {
+	return h
+}
+
+// Validate implements Response, it checks the success flag.
+func (h *ResponseHeader) Validate() error {
+	if h.Success != "true" {
+		return fmt.Errorf("%w: success flag is %q", utils.ErrKeepassxcInvalidResponse, h.Success)
+	}
+	return nil
+}
+
+// requireField returns an ErrKeepassxcInvalidResponse, if the value of the named response field is empty.
+func requireField(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%w: field %s missing", utils.ErrKeepassxcInvalidResponse, name)
+	}
+	return nil
+}
+
+// envelopeRequest is implemented by requests, that set fields of the unencrypted Envelope as well.
+type envelopeRequest interface {
+	Request
+	envelope(*Envelope)
+}
+
+// ActionRequest represents requests without parameters, e.g. lock-database.
+type ActionRequest struct {
+	Action string `json:"action"`
+}
+
+// RequestAction implements Request.
+func (r *ActionRequest) RequestAction() string {
+	return r.Action
+}
+
+// AssociateRequest represents the associate request.
+type AssociateRequest struct {
+	Action string `json:"action"`
+	// The public key of the client.
+	Key string `json:"key"`
+	// The new key identifying the association.
+	IdKey string `json:"idKey"`
+}
+
+// RequestAction implements Request.
+func (r *AssociateRequest) RequestAction() string {
+	return r.Action
+}
+
+// AssociateResponse represents the associate response.
+type AssociateResponse struct {
+	ResponseHeader
+	// The name of the association chosen by the user.
+	Id string `json:"id"`
+	// The hash of the associated database.
+	Hash string `json:"hash"`
+}
+
+// Validate implements Response.
+func (r *AssociateResponse) Validate() error {
+	return errors.Join(r.ResponseHeader.Validate(), requireField("id", r.Id))
+}
+
+// TestAssociateRequest represents the test-associate request.
+type TestAssociateRequest struct {
+	Action string `json:"action"`
+	// The name of the association.
+	Id string `json:"id"`
+	// The key identifying the association.
+	Key string `json:"key"`
+}
+
+// RequestAction implements Request.
+func (r *TestAssociateRequest) RequestAction() string {
+	return r.Action
+}
+
+// TestAssociateResponse represents the test-associate response.
+type TestAssociateResponse struct {
+	ResponseHeader
+	// The name of the association.
+	Id string `json:"id"`
+	// The hash of the database.
+	Hash string `json:"hash"`
+}
+
+// AssociationKey identifies an association within the GetLoginsRequest.
+type AssociationKey struct {
+	// The name of the association.
+	Id string `json:"id"`
+	// The key identifying the association.
+	Key string `json:"key"`
+}
+
+// GetLoginsRequest represents the get-logins request.
+type GetLoginsRequest struct {
+	Action string `json:"action"`
+	// The URL to find entries for.
+	Url string `json:"url"`
+	// The associations of all databases to search.
+	Keys []AssociationKey `json:"keys"`
+}
+
+// RequestAction implements Request.
+func (r *GetLoginsRequest) RequestAction() string {
+	return r.Action
+}
+
+// GetLoginsResponse represents the get-logins response.
+type GetLoginsResponse struct {
+	ResponseHeader
+	// The entries matching the URL.
+	Entries Entries `json:"entries"`
+	// The hash of the database.
+	Hash string `json:"hash"`
+}
+
+// Validate implements Response.
+func (r *GetLoginsResponse) Validate() error {
+	if r.Entries == nil {
+		return errors.Join(r.ResponseHeader.Validate(), requireField("entries", ""))
+	}
+	for _, entry := range r.Entries {
+		if entry == nil || entry.Uuid == "" {
+			return fmt.Errorf("%w: entry without uuid", utils.ErrKeepassxcInvalidResponse)
+		}
+	}
+	return r.ResponseHeader.Validate()
+}
+
+// GeneratePasswordResponse represents the generate-password response.
+type GeneratePasswordResponse struct {
+	ResponseHeader
+	// The generated password, since keepassxc 2.7.
+	Password Password `json:"password"`
+	// The generated password as password of a single entry, before keepassxc 2.7.
+	Entries Entries `json:"entries"`
+}
+
+// Generated returns the generated password of any keepassxc version.
+func (r *GeneratePasswordResponse) Generated() Password {
+	if r.Password == "" && len(r.Entries) > 0 && r.Entries[0] != nil {
+		return r.Entries[0].Password
+	}
+	return r.Password
+}
+
+// Validate implements Response.
+func (r *GeneratePasswordResponse) Validate() error {
+	return errors.Join(r.ResponseHeader.Validate(), requireField("password", string(r.Generated())))
+}
+
+// SetLoginRequest represents the set-login request.
+type SetLoginRequest struct {
+	Action string `json:"action"`
+	// The URL of the entry.
+	Url string `json:"url"`
+	// The URL the login form was submitted to, the same as Url for this tool.
+	SubmitUrl string `json:"submitUrl"`
+	// The name of the association.
+	Id string `json:"id"`
+	// The user name of the entry.
+	Login string `json:"login"`
+	// The password of the entry.
+	Password Password `json:"password"`
+	// The name of the group to create the entry in.
+	Group string `json:"group,omitempty"`
+	// The UUID of the group to create the entry in.
+	GroupUuid string `json:"groupUuid,omitempty"`
+	// The UUID of an existing entry to update.
+	Uuid string `json:"uuid,omitempty"`
+}
+
+// RequestAction implements Request.
+func (r *SetLoginRequest) RequestAction() string {
+	return r.Action
+}
+
+// SetLoginResponse represents the set-login response.
+type SetLoginResponse struct {
+	ResponseHeader
+}
+
+// GetDatabaseGroupsResponse represents the get-database-groups response.
+type GetDatabaseGroupsResponse struct {
+	ResponseHeader
+	// The name of the default group for new entries.
+	DefaultGroup string `json:"defaultGroup"`
+	// The group tree, keepassxc wraps it in an object.
+	Groups struct {
+		// The root groups.
+		Groups Groups `json:"groups"`
+	} `json:"groups"`
+}
+
+// Validate implements Response.
+func (r *GetDatabaseGroupsResponse) Validate() error {
+	if r.Groups.Groups == nil {
+		return errors.Join(r.ResponseHeader.Validate(), requireField("groups", ""))
+	}
+	return errors.Join(r.ResponseHeader.Validate(), validateGroups(r.Groups.Groups))
+}
+
+// validateGroups checks the group tree recursively for null groups, that would break Groups.Walk.
+func validateGroups(groups Groups) error {
+	for _, group := range groups {
+		if group == nil {
+			return fmt.Errorf("%w: null group", utils.ErrKeepassxcInvalidResponse)
+		}
+		if err := validateGroups(group.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateNewGroupRequest represents the create-new-group request.
+type CreateNewGroupRequest struct {
+	Action string `json:"action"`
+	// The full path of the group to create.
+	GroupName string `json:"groupName"`
+}
+
+// RequestAction implements Request.
+func (r *CreateNewGroupRequest) RequestAction() string {
+	return r.Action
+}
+
+// CreateNewGroupResponse represents the create-new-group response.
+type CreateNewGroupResponse struct {
+	ResponseHeader
+	// The name of the created group.
+	Name string `json:"name"`
+	// The UUID of the created group.
+	Uuid string `json:"uuid"`
+}
+
+// Validate implements Response.
+func (r *CreateNewGroupResponse) Validate() error {
+	return errors.Join(r.ResponseHeader.Validate(), requireField("uuid", r.Uuid))
+}
+
+// GetDatabaseHashRequest represents the get-databasehash request, that may show the unlock dialog.
+type GetDatabaseHashRequest struct {
+	Action string `json:"action"`
+	// Show the unlock dialog of keepassxc, if the database is locked. This is sent in the Envelope.
+	TriggerUnlock bool `json:"-"`
+}
+
+// RequestAction implements Request.
+func (r *GetDatabaseHashRequest) RequestAction() string {
+	return r.Action
+}
+
+// envelope implements envelopeRequest.
+func (r *GetDatabaseHashRequest) envelope(env *Envelope) {
+	if r.TriggerUnlock {
+		env.TriggerUnlock = "true"
+	}
+}
+
+// GetDatabaseHashResponse represents the get-databasehash response.
+type GetDatabaseHashResponse struct {
+	ResponseHeader
+	// The hash of the opened database.
+	Hash string `json:"hash"`
+}
+
+// Validate implements Response.
+func (r *GetDatabaseHashResponse) Validate() error {
+	return errors.Join(r.ResponseHeader.Validate(), requireField("hash", r.Hash))
+}
+
+// GetTOTPRequest represents the get-totp request.
+type GetTOTPRequest struct {
+	Action string `json:"action"`
+	// The UUID of the entry.
+	Uuid string `json:"uuid"`
+}
+
+// RequestAction implements Request.
+func (r *GetTOTPRequest) RequestAction() string {
+	return r.Action
+}
+
+// GetTOTPResponse represents the get-totp response.
+type GetTOTPResponse struct {
+	ResponseHeader
+	// The current totp of the entry, empty if the entry has none.
+	Totp string `json:"totp"`
+}
+
+// DeleteEntryRequest represents the delete-entry request.
+type DeleteEntryRequest struct {
+	Action string `json:"action"`
+	// The UUID of the entry.
+	Uuid string `json:"uuid"`
+}
+
+// RequestAction implements Request.
+func (r *DeleteEntryRequest) RequestAction() string {
+	return r.Action
+}
+
+// DeleteEntryResponse represents the delete-entry response.
+type DeleteEntryResponse struct {
+	ResponseHeader
+}
+
+// Validate implements Response.
+// Keepassxc answers with the success flag "false", if the entry was not found or the user denied the deletion.
+func (r *DeleteEntryResponse) Validate() error {
+	if r.Success == "false" {
+		return errors.Join(errors.New("keepassxc did not delete the entry, it was not found or the deletion was denied"),
+			utils.ErrKeepassxcActionCancelledOrDenied)
+	}
+	return r.ResponseHeader.Validate()
+}
+
+// LockDatabaseResponse represents the lock-database response.
+type LockDatabaseResponse struct {
+	ResponseHeader
+}
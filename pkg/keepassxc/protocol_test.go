@@ -0,0 +1,39 @@
+package keepassxc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+func TestGetDatabaseGroupsResponseValidate(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		json  string
+		valid bool
+	}{
+		{"tree", `{"success":"true","groups":{"groups":[{"name":"Root","uuid":"1","children":[{"name":"a","uuid":"2","children":[]}]}]}}`, true},
+		{"null children list", `{"success":"true","groups":{"groups":[{"name":"Root","uuid":"1","children":null}]}}`, true},
+		{"missing groups", `{"success":"true","groups":{}}`, false},
+		{"null root group", `{"success":"true","groups":{"groups":[null]}}`, false},
+		{"null child", `{"success":"true","groups":{"groups":[{"name":"Root","uuid":"1","children":[null]}]}}`, false},
+		{"null grandchild", `{"success":"true","groups":{"groups":[{"name":"Root","uuid":"1","children":[{"name":"a","uuid":"2","children":[null]}]}]}}`, false},
+	} {
+		var resp GetDatabaseGroupsResponse
+		if err := json.Unmarshal([]byte(test.json), &resp); err != nil {
+			t.Fatalf("%s: Unmarshal: %s", test.name, err)
+		}
+		err := resp.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: Validate = %v, want nil", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, utils.ErrKeepassxcInvalidResponse) {
+			t.Errorf("%s: Validate = %v, want %v", test.name, err, utils.ErrKeepassxcInvalidResponse)
+		}
+		if err == nil {
+			resp.Groups.Groups.setPaths("")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package keepassxc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+/*
+	Proxy transport implementation
+	The browser native messaging framing is used, each message is prefixed with its length
+	as 32-bit unsigned integer in native byte order.
+*/
+
+// ProxyDialer returns a Dialer, that starts the given command (usually keepassxc-proxy) for each connection
+// and talks to it via its stdin and stdout. This works for sandboxed keepassxc installations,
+// that do not expose the socket, e.g.:
+//
+//	ProxyDialer("flatpak", "run", "--command=keepassxc-proxy", "org.keepassxc.KeePassXC")
+//
+// The process is killed when the connection is closed.
+func ProxyDialer(command string, args ...string) Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return startProxy(exec.Command(command, args...))
+	}
+}
+
+// proxyAddr represents the command of a proxyConn as net.Addr.
+type proxyAddr string
+
+// Network implements net.Addr.
+func (a proxyAddr) Network() string {
+	return "proxy"
+}
+
+// String implements net.Addr.
+func (a proxyAddr) String() string {
+	return string(a)
+}
+
+// proxyConn represents the connection to a proxy process as net.Conn.
+// Each Write sends a single message, Read returns the message payloads without their length prefix.
+type proxyConn struct {
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+
+	readMu sync.Mutex
+	// the number of bytes left of the message currently read
+	remaining uint32
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// startProxy starts the command with pipes for stdin and stdout.
+// os.Pipe is used instead of cmd.StdinPipe, since its files support deadlines.
+func startProxy(cmd *exec.Cmd) (*proxyConn, error) {
+	stdinRead, stdinWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutRead, stdoutWrite, err := os.Pipe()
+	if err != nil {
+		stdinRead.Close()
+		stdinWrite.Close()
+		return nil, err
+	}
+	cmd.Stdin = stdinRead
+	cmd.Stdout = stdoutWrite
+	err = cmd.Start()
+	// the child ends are not needed anymore, closing them lets reads fail once the process exits
+	stdinRead.Close()
+	stdoutWrite.Close()
+	if err != nil {
+		stdinWrite.Close()
+		stdoutRead.Close()
+		return nil, err
+	}
+	return &proxyConn{cmd: cmd, stdin: stdinWrite, stdout: stdoutRead}, nil
+}
+
+// Read implements net.Conn, it strips the length prefixes of the messages.
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for c.remaining == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(c.stdout, header[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		c.remaining = binary.NativeEndian.Uint32(header[:])
+	}
+	if uint32(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+	n, err := c.stdout.Read(b)
+	c.remaining -= uint32(n)
+	return n, err
+}
+
+// Write implements net.Conn, it sends b as a single message with length prefix.
+func (c *proxyConn) Write(b []byte) (int, error) {
+	if uint64(len(b)) > math.MaxUint32 {
+		return 0, errors.New("message too large for the proxy")
+	}
+	data := make([]byte, 4, 4+len(b))
+	binary.NativeEndian.PutUint32(data, uint32(len(b)))
+	data = append(data, b...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	n, err := c.stdin.Write(data)
+	n -= 4
+	if n < 0 {
+		n = 0
+	}
+	return n, err
+}
+
+// Close implements net.Conn, it closes the pipes and kills the process.
+func (c *proxyConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = errors.Join(c.stdin.Close(), c.stdout.Close())
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	})
+	return err
+}
+
+// LocalAddr implements net.Conn.
+func (c *proxyConn) LocalAddr() net.Addr {
+	return proxyAddr(c.cmd.Path)
+}
+
+// RemoteAddr implements net.Conn.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return proxyAddr(c.cmd.Path)
+}
+
+// SetDeadline implements net.Conn.
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	return errors.Join(c.SetReadDeadline(t), c.SetWriteDeadline(t))
+}
+
+// SetReadDeadline implements net.Conn.
+// Pipes without deadline support (windows) ignore the deadline.
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	return ignoreNoDeadline(c.stdout.SetReadDeadline(t))
+}
+
+// SetWriteDeadline implements net.Conn.
+// Pipes without deadline support (windows) ignore the deadline.
+func (c *proxyConn) SetWriteDeadline(t time.Time) error {
+	return ignoreNoDeadline(c.stdin.SetWriteDeadline(t))
+}
+
+// ignoreNoDeadline drops os.ErrNoDeadline.
+func ignoreNoDeadline(err error) error {
+	if errors.Is(err, os.ErrNoDeadline) {
+		return nil
+	}
+	return err
+}
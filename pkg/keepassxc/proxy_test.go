@@ -0,0 +1,55 @@
+package keepassxc
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestProxyConnRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+	// cat echoes the framed messages, so the reads see the length prefixes of the writes
+	conn, err := ProxyDialer("cat")(context.Background())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+
+	var messages [][]byte
+	for i, size := range []int{1, 70000, 1 << 20, 3} {
+		messages = append(messages, bytes.Repeat([]byte{byte('a' + i)}, size))
+	}
+	go func() {
+		// the messages exceed the pipe buffer, so they are written while the test reads
+		for _, msg := range messages {
+			if _, err := conn.Write(msg); err != nil {
+				t.Errorf("Write: %s", err)
+				return
+			}
+		}
+	}()
+
+	// a single read never crosses a message boundary, larger messages are split across reads
+	buf := make([]byte, 4093)
+	for i, msg := range messages {
+		var got []byte
+		for len(got) < len(msg) {
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatalf("message %d: Read: %s", i, err)
+			}
+			got = append(got, buf[:n]...)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("message %d: read %d bytes, want %d bytes of %q", i, len(got), len(msg), msg[0])
+		}
+	}
+
+	conn.Close()
+	if _, err = conn.Read(buf); err == nil {
+		t.Fatalf("Read after Close = %v, want an error", err)
+	}
+}
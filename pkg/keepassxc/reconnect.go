@@ -0,0 +1,100 @@
+package keepassxc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Reconnect implementation
+*/
+
+// idempotentActions are the api actions, that are retried after a reconnect.
+var idempotentActions = map[string]bool{
+	utils.ActionGetLogins:         true,
+	utils.ActionTestAssociate:     true,
+	utils.ActionGeneratePassword:  true,
+	utils.ActionGetDatabaseGroups: true,
+	utils.ActionCreateNewGroup:    true,
+	utils.ActionGetDatabaseHash:   true,
+	utils.ActionLockDatabase:      true,
+	utils.ActionGetTotp:           true,
+}
+
+// ReconnectPolicy configures how a Client re-establishes a lost connection, e.g. after a keepassxc restart.
+type ReconnectPolicy struct {
+	// Number of connection attempts per lost connection, defaults to 5.
+	MaxAttempts int
+	// Delay before the second attempt, doubled for each further attempt, defaults to 500ms.
+	InitialBackoff time.Duration
+	// Upper limit of the delay between two attempts, defaults to 10s.
+	MaxBackoff time.Duration
+}
+
+// OptReconnect is an option to NewClient.
+// It enables reconnecting if the connection to keepassxc is lost.
+// The socket is dialed again, the keys are exchanged and the association is ensured,
+// then idempotent requests like GetLogins are retried transparently.
+// Zero values of the policy are replaced by their defaults.
+func OptReconnect(policy ReconnectPolicy) ClientOption {
+	return func(client *Client) error {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = 5
+		}
+		if policy.InitialBackoff <= 0 {
+			policy.InitialBackoff = 500 * time.Millisecond
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = 10 * time.Second
+		}
+		client.reconnect = &policy
+		return nil
+	}
+}
+
+// reconnectSession replaces the failed session by a new one according to the ReconnectPolicy.
+// If another request already replaced the failed session, this is a no-op.
+func (c *Client) reconnectSession(ctx context.Context, failed *session) error {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	c.mu.Lock()
+	current, closed := c.session, c.closed
+	c.mu.Unlock()
+	if closed {
+		return utils.ErrKeepassxcConnectionClosed
+	}
+	if current != failed {
+		return nil
+	}
+	failed.close()
+
+	backoff := c.reconnect.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var s *session
+		if s, err = c.dial(ctx); err == nil {
+			if err = c.ensureAssociation(ctx, s); err == nil {
+				c.mu.Lock()
+				c.session = s
+				c.mu.Unlock()
+				return nil
+			}
+			s.close()
+		}
+		if attempt >= c.reconnect.MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err, utils.ErrKeepassxcReconnectFailed)
+		case <-c.closedCh:
+			return utils.ErrKeepassxcConnectionClosed
+		}
+		backoff = min(2*backoff, c.reconnect.MaxBackoff)
+	}
+	return errors.Join(err, utils.ErrKeepassxcReconnectFailed)
+}
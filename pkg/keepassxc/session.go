@@ -0,0 +1,105 @@
+package keepassxc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kevinburke/nacl"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Session implementation
+*/
+
+// session represents a single connection to the api incl. its negotiated encryption key.
+// A Client replaces its session, if it has to reconnect.
+type session struct {
+	socket     net.Conn
+	dispatcher *dispatcher
+	writeMu    sync.Mutex
+	peerKey    nacl.Key
+}
+
+// newSession starts the reader goroutine for the given connection.
+// Unsolicited messages are published to the given events channel.
+func newSession(socket net.Conn, events chan Event) *session {
+	decoder := json.NewDecoder(socket)
+	return &session{
+		socket: socket,
+		dispatcher: newDispatcher(func() (*Envelope, error) {
+			return readResponse(decoder)
+		}, events),
+	}
+}
+
+// close closes the connection and waits for the reader goroutine to stop.
+func (s *session) close() error {
+	err := s.socket.Close()
+	<-s.dispatcher.done
+	return err
+}
+
+// send registers the request at the dispatcher and writes its data to the socket.
+// Both happen under the write lock, so the order of the pending requests equals the order on the wire,
+// which correlates error responses without nonce to the right request, see dispatcher.take.
+func (s *session) send(ctx context.Context, data []byte, action, nonce string) (*pendingRequest, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	req, err := s.dispatcher.register(action, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.write(ctx, data); err != nil {
+		s.dispatcher.unregister(req)
+		return nil, err
+	}
+	return req, nil
+}
+
+// write writes the data to the socket, honouring the deadline and cancellation of the context.
+// The caller has to hold writeMu.
+func (s *session) write(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := s.socket.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	defer s.socket.SetWriteDeadline(time.Time{})
+	// abort a blocking write on cancellation by moving the deadline to the past
+	stop := context.AfterFunc(ctx, func() {
+		s.socket.SetWriteDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+	if _, err := s.socket.Write(data); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errors.Join(ctxErr, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// readResponse reads exactly one json response from the socket, it is only used by the reader goroutine.
+// The api does not frame its messages, so the json decoder is used to find the end of each message.
+// This works for arbitrarily large responses, that may arrive split over multiple reads,
+// and keeps any following data buffered for the next call.
+// A message, that is valid json but no Envelope, fails with utils.ErrKeepassxcInvalidResponse.
+func readResponse(decoder *json.Decoder) (*Envelope, error) {
+	var data json.RawMessage
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	resp := &Envelope{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return nil, errors.Join(err, utils.ErrKeepassxcInvalidResponse)
+	}
+	return resp, nil
+}
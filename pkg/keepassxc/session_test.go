@@ -0,0 +1,138 @@
+package keepassxc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+// chunkReader returns the data of the reader in reads of at most size bytes.
+type chunkReader struct {
+	reader io.Reader
+	size   int
+}
+
+// Read implements io.Reader.
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(p) > r.size {
+		p = p[:r.size]
+	}
+	return r.reader.Read(p)
+}
+
+func TestReadResponseLargeSplitMessages(t *testing.T) {
+	large := strings.Repeat("A", 3<<20)
+	var stream strings.Builder
+	for _, env := range []*Envelope{
+		{Action: utils.ActionGetLogins, Message: large, Nonce: "first"},
+		{Action: utils.ActionDatabaseLocked},
+		{Action: utils.ActionGetLogins, Message: large, Nonce: "second"},
+	} {
+		data, err := json.Marshal(env)
+		if err != nil {
+			t.Fatalf("Marshal: %s", err)
+		}
+		stream.Write(data)
+	}
+
+	// odd chunk sizes split the messages and their boundaries at arbitrary positions
+	for _, size := range []int{4093, 64 << 10} {
+		decoder := json.NewDecoder(&chunkReader{reader: strings.NewReader(stream.String()), size: size})
+		for _, want := range []struct{ action, nonce, message string }{
+			{utils.ActionGetLogins, "first", large},
+			{utils.ActionDatabaseLocked, "", ""},
+			{utils.ActionGetLogins, "second", large},
+		} {
+			resp, err := readResponse(decoder)
+			if err != nil {
+				t.Fatalf("chunk size %d: readResponse: %s", size, err)
+			}
+			if resp.Action != want.action || resp.Nonce != want.nonce || resp.Message != want.message {
+				t.Fatalf("chunk size %d: got %s/%s with %d bytes, want %s/%s with %d bytes", size,
+					resp.Action, resp.Nonce, len(resp.Message), want.action, want.nonce, len(want.message))
+			}
+		}
+		if _, err := readResponse(decoder); !errors.Is(err, io.EOF) {
+			t.Fatalf("chunk size %d: readResponse at the end = %v, want %v", size, err, io.EOF)
+		}
+	}
+}
+
+func TestReadResponseInvalidEnvelope(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`["no envelope"]{"action":"get-logins"}`))
+	if _, err := readResponse(decoder); !errors.Is(err, utils.ErrKeepassxcInvalidResponse) {
+		t.Fatalf("readResponse = %v, want %v", err, utils.ErrKeepassxcInvalidResponse)
+	}
+	if resp, err := readResponse(decoder); err != nil || resp.Action != utils.ActionGetLogins {
+		t.Fatalf("readResponse after an invalid envelope = %v, %v, want the next message", resp, err)
+	}
+}
+
+// blockingConn blocks the first write until release is closed.
+type blockingConn struct {
+	net.Conn
+	once    sync.Once
+	writing chan struct{}
+	release chan struct{}
+}
+
+// Write implements net.Conn.
+func (c *blockingConn) Write(p []byte) (int, error) {
+	c.once.Do(func() {
+		close(c.writing)
+		<-c.release
+	})
+	return c.Conn.Write(p)
+}
+
+// pendingNonces returns the nonces of the pending requests of the action in queue order.
+func pendingNonces(d *dispatcher, action string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var nonces []string
+	for _, req := range d.pending[action] {
+		nonces = append(nonces, req.nonce)
+	}
+	return nonces
+}
+
+func TestSessionSendRegistersInWireOrder(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go io.Copy(io.Discard, serverConn)
+	conn := &blockingConn{Conn: clientConn, writing: make(chan struct{}), release: make(chan struct{})}
+	s := newSession(conn, make(chan Event, 1))
+	defer s.close()
+
+	var wg sync.WaitGroup
+	send := func(nonce string) {
+		defer wg.Done()
+		if _, err := s.send(context.Background(), []byte("{}"), utils.ActionGetLogins, nonce); err != nil {
+			t.Errorf("send %s: %s", nonce, err)
+		}
+	}
+	wg.Add(2)
+	go send("first")
+	<-conn.writing
+	go send("second")
+
+	// the second request must not be registered before the first one is on the wire,
+	// otherwise an error response without nonce could be taken by the wrong request
+	time.Sleep(50 * time.Millisecond)
+	if nonces := pendingNonces(s.dispatcher, utils.ActionGetLogins); len(nonces) != 1 || nonces[0] != "first" {
+		t.Fatalf("pending during the first write = %v, want [first]", nonces)
+	}
+	close(conn.release)
+	wg.Wait()
+	if nonces := pendingNonces(s.dispatcher, utils.ActionGetLogins); len(nonces) != 2 || nonces[1] != "second" {
+		t.Fatalf("pending after both writes = %v, want [first second]", nonces)
+	}
+}
@@ -0,0 +1,77 @@
+package keepassxc
+
+import (
+	"context"
+	"net"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Transport implementation
+*/
+
+// Dialer represents a function that opens a new connection to the api.
+// It is called for the initial connection and for each reconnect, see OptReconnect.
+type Dialer func(ctx context.Context) (net.Conn, error)
+
+// SocketDialer returns the default Dialer, which connects to the socket (named pipe on windows) at socketPath.
+// On linux the socket has to be owned by the current user and the peer process has to run as the current user.
+func SocketDialer(socketPath string) Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		return connect(ctx, socketPath)
+	}
+}
+
+// OptDialer is an option to NewClient.
+// It replaces the socket connection with a custom Dialer, e.g. to connect to a forwarded socket.
+// The socket path is neither detected nor used then.
+func OptDialer(dialer Dialer) ClientOption {
+	return func(client *Client) error {
+		client.dialer = dialer
+		return nil
+	}
+}
+
+// OptPeerExecutable is an option to NewClient.
+// Connections are refused with utils.ErrKeepassxcPeerVerificationFailed, if the peer process
+// is not the given executable, e.g. "/usr/bin/keepassxc". This is only supported for unix sockets on linux.
+// The check applies to custom Dialers as well.
+func OptPeerExecutable(executable string) ClientOption {
+	return func(client *Client) error {
+		client.peerExecutable = executable
+		return nil
+	}
+}
+
+// peerExecutableDialer wraps the Dialer with the check of OptPeerExecutable.
+func peerExecutableDialer(dialer Dialer, executable string) Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		conn, err := dialer(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err = verifyPeerExecutable(conn, executable); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// OptConn is an option to NewClient.
+// The client uses the given, already established connection instead of connecting to the socket.
+// The connection can only be used once, so reconnects fail with utils.ErrKeepassxcConnectionClosed,
+// use OptDialer if reconnects are needed.
+func OptConn(conn net.Conn) ClientOption {
+	conns := make(chan net.Conn, 1)
+	conns <- conn
+	return OptDialer(func(ctx context.Context) (net.Conn, error) {
+		select {
+		case conn := <-conns:
+			return conn, nil
+		default:
+			return nil, utils.ErrKeepassxcConnectionClosed
+		}
+	})
+}
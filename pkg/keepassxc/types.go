@@ -2,9 +2,12 @@ package keepassxc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"keepassxc-http-tools-go/pkg/totp"
 	"keepassxc-http-tools-go/pkg/utils"
 	"strings"
+	"time"
 
 	"github.com/kevinburke/nacl"
 )
@@ -62,6 +65,7 @@ type Entry struct {
 	// The password of the password entry.
 	Password Password `json:"password"`
 	// The current generated totp of the password entry, if a totp is set up.
+	// See Entry.TotpKey() for entries, whose totp is only available as string field.
 	Totp string `json:"totp"`
 	// The group/folder of the password entry inside the database.
 	Group string `json:"group"`
@@ -70,6 +74,13 @@ type Entry struct {
 	// The user defined additional fields of the password entry.
 	// See ToMap() for an actual usable representation.
 	StringFields StringFields `json:"stringFields"`
+	// The full path of the group inside the database, e.g. "/scripts/clip".
+	// The api only returns the group name, so this is empty until Entries.ResolveGroupPaths() was called,
+	// it stays empty if the group name is ambiguous.
+	GroupPath string `json:"-"`
+
+	// all paths of groups with the name of the entry's group, see Entries.ResolveGroupPaths()
+	groupPaths []string
 }
 
 // StringFieldsMap converts the StringFields structure returned from the api (list of single entry maps)
@@ -79,7 +90,20 @@ func (e Entry) StringFieldsMap() map[string]Password {
 	return e.StringFields.ToMap()
 }
 
+// TotpKey parses the otpauth URI of the string field "KPH: otp" of this Entry, see totp.Parse().
+// This allows to generate totps locally, if keepassxc does not return them, e.g. for entries with another URL.
+// If the entry has no such string field, this fails with utils.ErrKeepassxcTotpNotFound.
+func (e Entry) TotpKey() (*totp.Key, error) {
+	uri, ok := e.StringFields.ToMap()[utils.TotpStringField]
+	if !ok || uri == "" {
+		return nil, errors.Join(fmt.Errorf("entry %s has no string field %s%s", e.Uuid,
+			utils.StringFieldKeyPrefix, utils.TotpStringField), utils.ErrKeepassxcTotpNotFound)
+	}
+	return totp.Parse(uri.Plaintext())
+}
+
 // GetByString returns the value of a property of this Entry by its name as a string.
+// The "totp" is generated locally by Entry.TotpKey(), if keepassxc did not return one.
 func (e Entry) GetByString(key string) string {
 	switch key {
 	case "name":
@@ -89,7 +113,13 @@ func (e Entry) GetByString(key string) string {
 	case "password":
 		return e.Password.Plaintext()
 	case "totp":
-		return e.Totp
+		if e.Totp != "" {
+			return e.Totp
+		}
+		if totpKey, err := e.TotpKey(); err == nil {
+			return totpKey.Generate(time.Now())
+		}
+		return ""
 	case "group":
 		return e.Group
 	case "uuid":
@@ -140,22 +170,134 @@ func (e Entries) FilterByName(name ...string) Entries {
 	return newEntries[:count]
 }
 
-// FilterByGroup filters the Entries collection by the given group names.
-// The entries have to match any group exactly.
+// FilterByGroup filters the Entries collection by the given groups.
+// The entries have to match any group.
+// A group starting with "/" is a full path pattern, see utils.MatchGroupPath(),
+// it only matches after Entries.ResolveGroupPaths() was called.
+// If the entry's group name is ambiguous, all groups with that name have to match the pattern.
+// Any other group is a group name, that has to match the entry's group name exactly.
 func (e Entries) FilterByGroup(group ...string) Entries {
 	newEntries := make(Entries, len(e))
 	count := 0
 	for _, entry := range e {
 		for _, g := range group {
-			if entry.Group == g {
+			if entry.matchesGroup(g) {
 				newEntries[count] = entry
 				count += 1
+				break
 			}
 		}
 	}
 	return newEntries[:count]
 }
 
+// ResolveGroupPaths resolves the full group paths of the entries by the group tree of the database.
+// See Entry.GroupPath and Client.GetDatabaseGroups().
+func (e Entries) ResolveGroupPaths(groups Groups) {
+	for _, entry := range e {
+		entry.groupPaths = nil
+		for _, group := range groups.FindByName(entry.Group) {
+			entry.groupPaths = append(entry.groupPaths, group.Path)
+		}
+		entry.GroupPath = ""
+		if len(entry.groupPaths) == 1 {
+			entry.GroupPath = entry.groupPaths[0]
+		}
+	}
+}
+
+// matchesGroup checks the entry against a single group filter, see Entries.FilterByGroup().
+func (e *Entry) matchesGroup(group string) bool {
+	if !strings.HasPrefix(group, "/") {
+		return e.Group == group
+	}
+	if len(e.groupPaths) == 0 {
+		return false
+	}
+	for _, path := range e.groupPaths {
+		if !utils.MatchGroupPath(group, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// Group represents a group (folder) of the database as returned by the keepassxc http api.
+type Group struct {
+	// The name of the group.
+	Name string `json:"name"`
+	// The UUID of the group.
+	Uuid string `json:"uuid"`
+	// The sub groups of the group.
+	Children Groups `json:"children"`
+	// The full path of the group inside the database, e.g. "/scripts/clip".
+	// The root group has the path "/", its name is not part of the paths.
+	Path string `json:"-"`
+}
+
+// Groups represents a list of Group objects.
+type Groups []*Group
+
+// Walk calls fn for each group of the tree, parents before their children.
+func (g Groups) Walk(fn func(*Group)) {
+	for _, group := range g {
+		fn(group)
+		group.Children.Walk(fn)
+	}
+}
+
+// FindByPath returns the group with the given full path or nil, if there is none.
+func (g Groups) FindByPath(path string) *Group {
+	var found *Group
+	g.Walk(func(group *Group) {
+		if found == nil && group.Path == path {
+			found = group
+		}
+	})
+	return found
+}
+
+// FindByName returns all groups with the given name.
+func (g Groups) FindByName(name string) Groups {
+	var found Groups
+	g.Walk(func(group *Group) {
+		if group.Name == name {
+			found = append(found, group)
+		}
+	})
+	return found
+}
+
+// setPaths fills the Path of all groups, the given groups are root groups.
+func (g Groups) setPaths(parent string) {
+	for _, group := range g {
+		if parent == "" {
+			group.Path = "/"
+		} else {
+			group.Path = strings.TrimSuffix(parent, "/") + "/" + group.Name
+		}
+		group.Children.setPaths(group.Path)
+	}
+}
+
+// LoginData represents the data of an entry to create or update with Client.SetLogin.
+type LoginData struct {
+	// The URL of the entry, e.g. the script indicator URL.
+	Url string
+	// The user name of the entry.
+	Login string
+	// The password of the entry.
+	Password Password
+	// The UUID of an existing entry to update, leave empty to create a new entry.
+	Uuid string
+	// The name of the group to create the entry in, leave empty for keepassxc's default.
+	// Keepassxc only uses the group, if GroupUuid is set as well, see Groups.FindByPath().
+	Group string
+	// The UUID of the group to create the entry in, leave empty for keepassxc's default.
+	// Keepassxc only uses the group, if the UUID exists and Group is set as well.
+	GroupUuid string
+}
+
 /*
 	client helper structs
 */
@@ -172,29 +314,49 @@ type KeepassxcClientProfile interface {
 	SetAssoc(string, nacl.Key) error
 }
 
-// Message represents the api input from the client.
-type Message map[string]interface{}
+// Implement this interface additionally to KeepassxcClientProfile to use multiple databases.
+// The associations are identified by the hash of their database, see Client.GetDatabaseHash().
+// New databases are associated on demand, GetLogins queries all associated databases.
+// The KeepassxcClientProfile methods are only used to take over a single existing association.
+type KeepassxcMultiDatabaseProfile interface {
+	KeepassxcClientProfile
+	// GetDatabaseHashes returns the hashes of all databases the profile is associated with.
+	GetDatabaseHashes() []string
+	// GetDatabaseAssoc returns the association name and nacl.Key of the profile for the database hash.
+	// If not yet associated with that database, the key is supposed to be nil.
+	GetDatabaseAssoc(string) (string, nacl.Key)
+	// SetDatabaseAssoc saves the association name and nacl.Key for the database hash in the profile.
+	SetDatabaseAssoc(string, string, nacl.Key) error
+}
 
-// Response represents the api response to the client.
-type Response map[string]interface{}
+// Implement this interface additionally to KeepassxcMultiDatabaseProfile to use database pinning,
+// see OptDatabasePinning. The pinned databases are recorded separately from the associations,
+// so a database associated despite a warning does not count as pinned.
+type KeepassxcPinningProfile interface {
+	KeepassxcMultiDatabaseProfile
+	// GetPinnedDatabaseHashes returns the hashes of the pinned databases.
+	GetPinnedDatabaseHashes() []string
+	// SetPinnedDatabaseHashes saves the hashes of the pinned databases in the profile.
+	SetPinnedDatabaseHashes([]string) error
+}
 
-// entries tries to parse the entries from an api response.
-func (r Response) entries() (Entries, error) {
-	var data []byte
-	if msg, ok := r["message"]; ok {
-		if v, ok := msg.(map[string]interface{})["entries"]; ok {
-			var err error
-			data, err = json.Marshal(v)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-	if len(data) == 0 {
-		return nil, utils.ErrKeepassxcInvalidResponse
-	}
+// Implement this interface additionally to KeepassxcClientProfile to persist the client identity,
+// see OptPersistentIdentity. The identity consists of the client id and the private nacl.Key of the client,
+// it is used for the encryption and identifies the client in the keepassxc logs.
+// The private key is sensible data and has to be stored securely.
+type KeepassxcClientIdentityProfile interface {
+	KeepassxcClientProfile
+	// GetClientIdentity returns the client id and the private nacl.Key of the client.
+	// If no identity is saved yet, the key is supposed to be nil.
+	GetClientIdentity() (string, nacl.Key)
+	// SetClientIdentity saves the client id and the private nacl.Key of the client in the profile.
+	SetClientIdentity(string, nacl.Key) error
+}
 
-	var entries Entries
-	err := json.Unmarshal(data, &entries)
-	return entries, err
+// association represents the association of a client profile with a single database.
+type association struct {
+	// The name (id) of the association, as returned by the api.
+	name string
+	// The key of the association.
+	key nacl.Key
 }
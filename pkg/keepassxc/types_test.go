@@ -0,0 +1,77 @@
+package keepassxc_test
+
+import (
+	"testing"
+
+	"keepassxc-http-tools-go/pkg/keepassxc"
+)
+
+func TestFilterByGroup(t *testing.T) {
+	client, server := newTestClient(t)
+	for _, path := range []string{"/scripts/clip", "/scripts/deploy", "/work/a/prod", "/work/b/prod", "/work/test", "/personal/prod"} {
+		server.AddGroup(path)
+	}
+	groups, err := client.GetDatabaseGroups()
+	if err != nil {
+		t.Fatalf("GetDatabaseGroups: %s", err)
+	}
+
+	clip := &keepassxc.Entry{Name: "clip", Group: "clip"}
+	deploy := &keepassxc.Entry{Name: "deploy", Group: "deploy"}
+	prod := &keepassxc.Entry{Name: "prod", Group: "prod"}
+	work := &keepassxc.Entry{Name: "test", Group: "test"}
+	unknown := &keepassxc.Entry{Name: "unknown", Group: "unknown"}
+	entries := keepassxc.Entries{clip, deploy, prod, work, unknown}
+	entries.ResolveGroupPaths(groups)
+
+	if clip.GroupPath != "/scripts/clip" || work.GroupPath != "/work/test" {
+		t.Fatalf("GroupPath = %q and %q, want /scripts/clip and /work/test", clip.GroupPath, work.GroupPath)
+	}
+	// the folder name "prod" is ambiguous
+	if prod.GroupPath != "" || unknown.GroupPath != "" {
+		t.Fatalf("GroupPath of ambiguous and unknown groups = %q and %q, want empty", prod.GroupPath, unknown.GroupPath)
+	}
+
+	for _, test := range []struct {
+		filters []string
+		names   []string
+	}{
+		{[]string{"/scripts/**"}, []string{"clip", "deploy"}},
+		{[]string{"/scripts/clip"}, []string{"clip"}},
+		// /personal/prod is named "prod" as well, so the entry is excluded
+		{[]string{"/work/*/prod"}, nil},
+		{[]string{"/**/prod"}, []string{"prod"}},
+		{[]string{"/work/**"}, []string{"test"}},
+		{[]string{"/unknown", "/**/unknown"}, nil},
+		// old configs with bare group names
+		{[]string{"clip"}, []string{"clip"}},
+		{[]string{"prod", "test"}, []string{"prod", "test"}},
+		{[]string{"scripts"}, nil},
+		{[]string{"clip", "/work/**"}, []string{"clip", "test"}},
+	} {
+		var names []string
+		for _, entry := range entries.FilterByGroup(test.filters...) {
+			names = append(names, entry.Name)
+		}
+		if len(names) != len(test.names) {
+			t.Errorf("FilterByGroup(%q) = %q, want %q", test.filters, names, test.names)
+			continue
+		}
+		for i := range names {
+			if names[i] != test.names[i] {
+				t.Errorf("FilterByGroup(%q) = %q, want %q", test.filters, names, test.names)
+				break
+			}
+		}
+	}
+}
+
+func TestFilterByGroupUnresolved(t *testing.T) {
+	entries := keepassxc.Entries{{Name: "clip", Group: "clip"}}
+	if filtered := entries.FilterByGroup("/**"); len(filtered) != 0 {
+		t.Fatalf("path filter without ResolveGroupPaths = %d entries, want none", len(filtered))
+	}
+	if filtered := entries.FilterByGroup("clip"); len(filtered) != 1 {
+		t.Fatalf("name filter without ResolveGroupPaths = %d entries, want 1", len(filtered))
+	}
+}
@@ -0,0 +1,66 @@
+package keepassxc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	Wait for unlock implementation
+*/
+
+// OptWaitForUnlock is an option to NewClient.
+// If the database is locked, the unlock dialog of keepassxc is shown and the client waits for the
+// utils.ActionDatabaseUnlocked notification, instead of failing with utils.ErrKeepassxcDatabaseNotOpened.
+// This is done on connection, on reconnects and for requests failing because the database was locked meanwhile.
+// The timeout limits each wait, 0 means the wait is only limited by the context.
+func OptWaitForUnlock(timeout time.Duration) ClientOption {
+	return func(client *Client) error {
+		client.waitUnlock = true
+		client.unlockTimeout = timeout
+		return nil
+	}
+}
+
+// WaitForUnlock shows the unlock dialog of keepassxc, if the database is locked, and waits until it is unlocked.
+// See WaitForUnlockContext.
+func (c *Client) WaitForUnlock() error {
+	return c.WaitForUnlockContext(context.Background())
+}
+
+// WaitForUnlockContext shows the unlock dialog of keepassxc, if the database is locked, and waits until it is unlocked.
+// The context limits the wait, the timeout of OptWaitForUnlock is not applied.
+func (c *Client) WaitForUnlockContext(ctx context.Context) error {
+	return c.waitForUnlock(ctx, c.currentSession(), 0)
+}
+
+// waitForUnlock is the WaitForUnlock implementation for the given session, without reconnects.
+// The unlocked notification is watched before the dialog is triggered, so it can not be missed.
+func (c *Client) waitForUnlock(ctx context.Context, s *session, timeout time.Duration) error {
+	unlocked, stop := s.dispatcher.watch(utils.ActionDatabaseUnlocked)
+	defer stop()
+	err := c.sendMessage(ctx, s, &GetDatabaseHashRequest{
+		Action:        utils.ActionGetDatabaseHash,
+		TriggerUnlock: true,
+	}, &GetDatabaseHashResponse{})
+	if !errors.Is(err, utils.ErrKeepassxcDatabaseNotOpened) {
+		return err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	select {
+	case <-unlocked:
+		return nil
+	case <-s.dispatcher.done:
+		return errors.Join(err, utils.ErrKeepassxcConnectionClosed)
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), err)
+	}
+}
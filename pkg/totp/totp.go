@@ -0,0 +1,179 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+/*
+	TOTP implementation (RFC 6238)
+*/
+
+// Algorithm represents the HMAC hash function of a Key.
+type Algorithm string
+
+const (
+	// HMAC-SHA1, the default.
+	SHA1 Algorithm = "SHA1"
+	// HMAC-SHA256.
+	SHA256 Algorithm = "SHA256"
+	// HMAC-SHA512.
+	SHA512 Algorithm = "SHA512"
+)
+
+// Encoder represents how the code of a Key is encoded.
+type Encoder string
+
+const (
+	// Decimal digits, the default.
+	EncoderDefault Encoder = ""
+	// The alphanumeric characters of Steam Guard.
+	EncoderSteam Encoder = "steam"
+)
+
+// steamAlphabet are the characters of Steam Guard codes.
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// Key represents the settings of a totp, as parsed from an otpauth URI.
+type Key struct {
+	// The issuer of the totp, e.g. "Example Corp".
+	Issuer string
+	// The account name of the totp, e.g. "alice@example.com".
+	Account string
+	// The shared secret (decoded from base32).
+	Secret []byte
+	// The HMAC hash function.
+	Algorithm Algorithm
+	// The number of digits (or characters for EncoderSteam) of the code.
+	Digits int
+	// The period each code is valid for.
+	Period time.Duration
+	// The encoding of the code.
+	Encoder Encoder
+}
+
+// Parse parses an otpauth URI like keepassxc stores it in the "otp" attribute of an entry, e.g.
+// "otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example&algorithm=SHA256&digits=8&period=60".
+// Steam Guard is supported by the parameter "encoder=steam". Invalid URIs fail with utils.ErrTotpInvalidUri.
+func Parse(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, errors.Join(err, utils.ErrTotpInvalidUri)
+	}
+	if !strings.EqualFold(u.Scheme, "otpauth") || !strings.EqualFold(u.Host, "totp") {
+		return nil, errors.Join(fmt.Errorf("expected otpauth://totp/, got %s://%s/", u.Scheme, u.Host),
+			utils.ErrTotpInvalidUri)
+	}
+	query := u.Query()
+	key := &Key{
+		Algorithm: SHA1,
+		Digits:    utils.TotpDefaultDigits,
+		Period:    utils.TotpDefaultPeriod * time.Second,
+		Issuer:    query.Get("issuer"),
+	}
+
+	// the label is "issuer:account" or just "account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Account = strings.TrimSpace(account)
+		if key.Issuer == "" {
+			key.Issuer = issuer
+		}
+	} else {
+		key.Account = label
+	}
+
+	secret := strings.TrimRight(strings.ToUpper(strings.ReplaceAll(query.Get("secret"), " ", "")), "=")
+	if key.Secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid secret: %w", err), utils.ErrTotpInvalidUri)
+	}
+	if len(key.Secret) == 0 {
+		return nil, errors.Join(errors.New("missing secret"), utils.ErrTotpInvalidUri)
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = Algorithm(strings.ToUpper(algorithm))
+		if key.hash() == nil {
+			return nil, errors.Join(fmt.Errorf("unsupported algorithm %s", algorithm), utils.ErrTotpInvalidUri)
+		}
+	}
+	if encoder := query.Get("encoder"); encoder != "" {
+		if !strings.EqualFold(encoder, string(EncoderSteam)) {
+			return nil, errors.Join(fmt.Errorf("unsupported encoder %s", encoder), utils.ErrTotpInvalidUri)
+		}
+		key.Encoder = EncoderSteam
+		key.Digits = utils.TotpSteamDigits
+	}
+	if digits := query.Get("digits"); digits != "" && key.Encoder != EncoderSteam {
+		if key.Digits, err = strconv.Atoi(digits); err != nil || key.Digits < 1 || key.Digits > 10 {
+			return nil, errors.Join(fmt.Errorf("invalid digits %s", digits), utils.ErrTotpInvalidUri)
+		}
+	}
+	if period := query.Get("period"); period != "" {
+		seconds, err := strconv.Atoi(period)
+		if err != nil || seconds < 1 {
+			return nil, errors.Join(fmt.Errorf("invalid period %s", period), utils.ErrTotpInvalidUri)
+		}
+		key.Period = time.Duration(seconds) * time.Second
+	}
+	return key, nil
+}
+
+// Generate returns the code of the key for the given time.
+func (k *Key) Generate(at time.Time) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(at.Unix()/int64(k.Period/time.Second)))
+	mac := hmac.New(k.hash(), k.Secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	if k.Encoder == EncoderSteam {
+		chars := make([]byte, k.Digits)
+		for i := range chars {
+			chars[i] = steamAlphabet[code%uint32(len(steamAlphabet))]
+			code /= uint32(len(steamAlphabet))
+		}
+		return string(chars)
+	}
+	modulo := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, uint64(code)%modulo)
+}
+
+// Remaining returns how long the code of the given time is valid.
+func (k *Key) Remaining(at time.Time) time.Duration {
+	return k.Period - time.Duration(at.UnixNano()%int64(k.Period))
+}
+
+// hash returns the hash function of the algorithm, or nil if it is not supported.
+func (k *Key) hash() func() hash.Hash {
+	switch k.Algorithm {
+	case SHA1:
+		return sha1.New
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
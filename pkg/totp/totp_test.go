@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"keepassxc-http-tools-go/pkg/utils"
+)
+
+// The seeds of RFC 6238 Appendix B, base32 encoded.
+const (
+	rfcSecretSHA1   = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	rfcSecretSHA256 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA===="
+	rfcSecretSHA512 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA="
+)
+
+func TestGenerateRFC6238(t *testing.T) {
+	keys := map[Algorithm]string{
+		SHA1:   "otpauth://totp/RFC:test?secret=" + rfcSecretSHA1 + "&digits=8",
+		SHA256: "otpauth://totp/RFC:test?secret=" + rfcSecretSHA256 + "&digits=8&algorithm=SHA256",
+		SHA512: "otpauth://totp/RFC:test?secret=" + rfcSecretSHA512 + "&digits=8&algorithm=sha512",
+	}
+	// the test vectors of RFC 6238 Appendix B
+	for _, test := range []struct {
+		unix  int64
+		codes map[Algorithm]string
+	}{
+		{59, map[Algorithm]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+		{1111111109, map[Algorithm]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+		{1111111111, map[Algorithm]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+		{1234567890, map[Algorithm]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+		{2000000000, map[Algorithm]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+		{20000000000, map[Algorithm]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+	} {
+		for algorithm, uri := range keys {
+			key, err := Parse(uri)
+			if err != nil {
+				t.Fatalf("Parse(%s): %s", uri, err)
+			}
+			if key.Algorithm != algorithm {
+				t.Fatalf("Parse(%s) algorithm = %s, want %s", uri, key.Algorithm, algorithm)
+			}
+			if code := key.Generate(time.Unix(test.unix, 0)); code != test.codes[algorithm] {
+				t.Errorf("%s at %d = %s, want %s", algorithm, test.unix, code, test.codes[algorithm])
+			}
+		}
+	}
+}
+
+func TestGenerateSteam(t *testing.T) {
+	key, err := Parse("otpauth://totp/Steam:alice?secret=jbsw%20y3dp%20ehpk%203pxp&encoder=steam&digits=8")
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+	if key.Encoder != EncoderSteam || key.Digits != utils.TotpSteamDigits {
+		t.Fatalf("Parse: encoder %q with %d digits, want steam with %d", key.Encoder, key.Digits, utils.TotpSteamDigits)
+	}
+	for unix, want := range map[int64]string{59: "2YXGV", 1700000000: "2KM2P"} {
+		if code := key.Generate(time.Unix(unix, 0)); code != want {
+			t.Errorf("steam at %d = %s, want %s", unix, code, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	key, err := Parse("otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&period=60")
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+	if key.Issuer != "Example" || key.Account != "alice@example.com" || key.Algorithm != SHA1 ||
+		key.Digits != utils.TotpDefaultDigits || key.Period != time.Minute {
+		t.Fatalf("Parse = %+v", key)
+	}
+	if remaining := key.Remaining(time.Unix(125, 0)); remaining != 55*time.Second {
+		t.Fatalf("Remaining = %s, want 55s", remaining)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, uri := range []string{
+		"",
+		"%zz",
+		"https://totp/Example?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://hotp/Example?secret=JBSWY3DPEHPK3PXP&counter=1",
+		"otpauth://totp/Example",
+		"otpauth://totp/Example?secret=not-base32!",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&digits=0",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&digits=11",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&digits=six",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&period=0",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&period=-30",
+		"otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP&encoder=base64",
+	} {
+		if key, err := Parse(uri); !errors.Is(err, utils.ErrTotpInvalidUri) || !errors.Is(err, utils.ErrTotp) {
+			t.Errorf("Parse(%q) = %+v, %v, want %v", uri, key, err, utils.ErrTotpInvalidUri)
+		}
+	}
+}
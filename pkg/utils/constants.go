@@ -9,10 +9,24 @@ const (
 	ConfigEnvPrefix = ApplicationNameShort
 	// Default file name to look for in user's config directory.
 	ConfigFileNameDefault = ApplicationNameShort + ".yaml"
+	// Config key path for the single association of older versions.
+	ConfigKeypathAssoc = "assoc"
 	// Config key path for association name.
-	ConfigKeypathAssocName = "assoc.name"
+	ConfigKeypathAssocName = ConfigKeypathAssoc + ".name"
 	// Config key path for association key, stored in base64.
-	ConfigKeypathAssocKey = "assoc.key"
+	ConfigKeypathAssocKey = ConfigKeypathAssoc + ".key"
+	// Config key path for the associations by database hash.
+	ConfigKeypathAssocs = "assocs"
+	// Config key of the association name within an association of ConfigKeypathAssocs.
+	ConfigKeySuffixAssocName = "name"
+	// Config key of the association key within an association of ConfigKeypathAssocs, stored in base64.
+	ConfigKeySuffixAssocKey = "key"
+	// Config key path for the persistent client identity.
+	ConfigKeypathClient = "client"
+	// Config key path for the persistent client id.
+	ConfigKeypathClientId = ConfigKeypathClient + ".id"
+	// Config key path for the persistent private key of the client, stored in base64.
+	ConfigKeypathClientKey = ConfigKeypathClient + ".key"
 	// Config key path for the formatter settings to use to identify keepassxc entries.
 	ConfigKeypathEntryIdentifier = "entryIdentifier"
 	// Config key path for the formatter settings to select the field to copy.
@@ -27,6 +41,58 @@ const (
 	ConfigKeypathScriptIndicatorUrl = "scriptIndicatorUrl"
 	// The default URL for ConfigKeypathScriptIndicatorUrl.
 	ConfigDefaultScriptIndicatorUrl = "script://keepassxc.go"
+	// Config key path for the transport to keepassxc, see TransportSocket and TransportProxy.
+	ConfigKeypathTransport = "transport"
+	// Transport via the socket (named pipe on windows) of keepassxc, the default.
+	TransportSocket = "socket"
+	// Transport via the keepassxc-proxy binary, e.g. for sandboxed keepassxc installations.
+	TransportProxy = "proxy"
+	// Config key path for the command (incl. arguments) to start keepassxc-proxy.
+	ConfigKeypathProxyCommand = "proxyCommand"
+	// The default command for ConfigKeypathProxyCommand.
+	ConfigDefaultProxyCommand = "keepassxc-proxy"
+	// Config key path for the expected executable of the keepassxc process behind the socket (linux only).
+	ConfigKeypathPeerExecutable = "peerExecutable"
+	// Config key path for the policy for databases that are not pinned, see keepassxc.DatabasePinning.
+	ConfigKeypathDatabasePinning = "databasePinning"
+	// Config key path for the list of the pinned database hashes.
+	ConfigKeypathPinnedDatabases = "pinnedDatabases"
+	// Action to exchange the encryption keys.
+	ActionChangePublicKeys = "change-public-keys"
+	// Action to associate the client with the database.
+	ActionAssociate = "associate"
+	// Action to test the association of the client with the database.
+	ActionTestAssociate = "test-associate"
+	// Action to find entries by URL.
+	ActionGetLogins = "get-logins"
+	// Action to generate a password with the keepassxc password generator.
+	ActionGeneratePassword = "generate-password"
+	// Action to create or update an entry.
+	ActionSetLogin = "set-login"
+	// Action to get the group tree of the database.
+	ActionGetDatabaseGroups = "get-database-groups"
+	// Action to create a group.
+	ActionCreateNewGroup = "create-new-group"
+	// Action to get the hash of the opened database.
+	ActionGetDatabaseHash = "get-databasehash"
+	// Action to lock the database.
+	ActionLockDatabase = "lock-database"
+	// Action to get the current totp of an entry.
+	ActionGetTotp = "get-totp"
+	// Action to delete an entry.
+	ActionDeleteEntry = "delete-entry"
+	// Action of the message the api sends unsolicited when the database gets locked.
+	ActionDatabaseLocked = "database-locked"
+	// Action of the message the api sends unsolicited when the database gets unlocked.
+	ActionDatabaseUnlocked = "database-unlocked"
+	// Period of totps in seconds, keepassxc does not return the period of an entry, so the default is assumed.
+	TotpDefaultPeriod = 30
+	// Number of digits of totps by default.
+	TotpDefaultDigits = 6
+	// Number of characters of Steam totps.
+	TotpSteamDigits = 5
+	// Name of the string field (without "KPH: ") holding the otpauth URI of an entry, like keepassxc names it.
+	TotpStringField = "otp"
 	// StringFields need this Prefix (incl. at least one space) to be returned by keepassxc http api.
 	StringFieldKeyPrefix = "KPH: "
 	// File name of the socket file of keepassxc http api.
@@ -47,4 +113,8 @@ const (
 	LinuxEnvTmpDirDefault = "/tmp"
 	// Linux snaps subdir in user home.
 	LinuxSnapCommonSubdir = "snap/keepassxc/common/"
+	// Linux flatpak subdir in the runtime files dir.
+	LinuxFlatpakAppSubdir = "app/org.keepassxc.KeePassXC/"
+	// Environment variable to override the socket detection with the path of the socket.
+	EnvSocketPath = "KPHT_SOCKET"
 )
@@ -1,6 +1,9 @@
 package utils
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// keepassxc lib generic base error
@@ -19,6 +22,121 @@ var (
 	ErrKeepassxcEncryptionFailed = errors.Join(errors.New("keepassxc failed to encrypt message"), ErrKeepassxc)
 	// keepassxc lib message decryption error
 	ErrKeepassxcDecryptionFailed = errors.Join(errors.New("keepassxc failed to decrypt message"), ErrKeepassxc)
+	// keepassxc lib socket connection error
+	ErrKeepassxcConnectFailed = errors.Join(errors.New("keepassxc failed to connect to the socket"), ErrKeepassxc)
+	// keepassxc lib connection closed error
+	ErrKeepassxcConnectionClosed = errors.Join(errors.New("keepassxc connection closed"), ErrKeepassxc)
+	// keepassxc lib reconnect failed error
+	ErrKeepassxcReconnectFailed = errors.Join(errors.New("keepassxc reconnect failed"), ErrKeepassxc)
+	// keepassxc lib response nonce does not match the request nonce error
+	ErrKeepassxcNonceMismatch = errors.Join(errors.New("keepassxc response nonce mismatch"), ErrKeepassxc)
 	// keepassxc lib send message error
 	ErrKeepassxcSendMessageFailed = errors.Join(errors.New("keepassxc failed send the message"), ErrKeepassxc)
+	// keepassxc lib client identity load or save error
+	ErrKeepassxcIdentityFailed = errors.Join(errors.New("keepassxc client identity failed"), ErrKeepassxc)
+	// keepassxc lib socket owner or peer process verification error
+	ErrKeepassxcPeerVerificationFailed = errors.Join(errors.New("keepassxc socket peer verification failed"), ErrKeepassxc)
+	// keepassxc lib opened database does not match the pinned databases error
+	ErrKeepassxcDatabaseNotPinned = errors.Join(errors.New("keepassxc database is not pinned"), ErrKeepassxc)
+	// keepassxc lib entry has no totp error
+	ErrKeepassxcTotpNotFound = errors.Join(errors.New("keepassxc entry has no totp"), ErrKeepassxc)
+	// keepassxc lib group path not found in the database error
+	ErrKeepassxcGroupNotFound = errors.Join(errors.New("keepassxc group not found"), ErrKeepassxc)
+)
+
+// Errors of the totp lib.
+var (
+	// totp lib generic base error
+	ErrTotp = errors.New("totp error")
+	// totp lib otpauth URI parse error
+	ErrTotpInvalidUri = errors.Join(errors.New("totp invalid otpauth uri"), ErrTotp)
 )
+
+// Errors returned by the keepassxc http api, see ProtocolError.
+var (
+	// keepassxc api generic error, for unknown error codes
+	ErrKeepassxcProtocol = errors.Join(errors.New("keepassxc api error"), ErrKeepassxc)
+	// keepassxc api error 1: database not opened (locked)
+	ErrKeepassxcDatabaseNotOpened = errors.Join(errors.New("keepassxc database not opened"), ErrKeepassxcProtocol)
+	// keepassxc api error 2: database hash not received
+	ErrKeepassxcDatabaseHashNotReceived = errors.Join(errors.New("keepassxc database hash not received"), ErrKeepassxcProtocol)
+	// keepassxc api error 3: client public key not received
+	ErrKeepassxcClientPublicKeyNotReceived = errors.Join(errors.New("keepassxc client public key not received"), ErrKeepassxcProtocol)
+	// keepassxc api error 4: keepassxc cannot decrypt the message
+	ErrKeepassxcCannotDecryptMessage = errors.Join(errors.New("keepassxc cannot decrypt message"), ErrKeepassxcProtocol)
+	// keepassxc api error 5: timeout or not connected to keepassxc
+	ErrKeepassxcTimeoutOrNotConnected = errors.Join(errors.New("keepassxc timeout or not connected"), ErrKeepassxcProtocol)
+	// keepassxc api error 6: action cancelled or denied by the user
+	ErrKeepassxcActionCancelledOrDenied = errors.Join(errors.New("keepassxc action cancelled or denied"), ErrKeepassxcProtocol)
+	// keepassxc api error 7: keepassxc cannot encrypt the message
+	ErrKeepassxcCannotEncryptMessage = errors.Join(errors.New("keepassxc cannot encrypt message"), ErrKeepassxcProtocol)
+	// keepassxc api error 10: encryption key is not recognized
+	ErrKeepassxcEncryptionKeyUnrecognized = errors.Join(errors.New("keepassxc encryption key is not recognized"), ErrKeepassxcProtocol)
+	// keepassxc api error 11: no saved databases found
+	ErrKeepassxcNoSavedDatabasesFound = errors.Join(errors.New("keepassxc no saved databases found"), ErrKeepassxcProtocol)
+	// keepassxc api error 12: incorrect action
+	ErrKeepassxcIncorrectAction = errors.Join(errors.New("keepassxc incorrect action"), ErrKeepassxcProtocol)
+	// keepassxc api error 13: empty message received
+	ErrKeepassxcEmptyMessageReceived = errors.Join(errors.New("keepassxc empty message received"), ErrKeepassxcProtocol)
+	// keepassxc api error 14: no URL provided
+	ErrKeepassxcNoUrlProvided = errors.Join(errors.New("keepassxc no URL provided"), ErrKeepassxcProtocol)
+	// keepassxc api error 15: no logins found
+	ErrKeepassxcNoLoginsFound = errors.Join(errors.New("keepassxc no logins found"), ErrKeepassxcProtocol)
+	// keepassxc api error 16: no groups found
+	ErrKeepassxcNoGroupsFound = errors.Join(errors.New("keepassxc no groups found"), ErrKeepassxcProtocol)
+	// keepassxc api error 17: cannot create new group
+	ErrKeepassxcCannotCreateNewGroup = errors.Join(errors.New("keepassxc cannot create new group"), ErrKeepassxcProtocol)
+	// keepassxc api error 18: no valid UUID provided
+	ErrKeepassxcNoValidUuidProvided = errors.Join(errors.New("keepassxc no valid UUID provided"), ErrKeepassxcProtocol)
+	// keepassxc api error 19: access to all entries is denied
+	ErrKeepassxcAccessToAllEntriesDenied = errors.Join(errors.New("keepassxc access to all entries is denied"), ErrKeepassxcProtocol)
+)
+
+// protocolErrors maps the error codes of the keepassxc http api to their errors.
+// The codes 8 and 9 are mapped to the matching lib errors.
+var protocolErrors = map[int]error{
+	1:  ErrKeepassxcDatabaseNotOpened,
+	2:  ErrKeepassxcDatabaseHashNotReceived,
+	3:  ErrKeepassxcClientPublicKeyNotReceived,
+	4:  ErrKeepassxcCannotDecryptMessage,
+	5:  ErrKeepassxcTimeoutOrNotConnected,
+	6:  ErrKeepassxcActionCancelledOrDenied,
+	7:  ErrKeepassxcCannotEncryptMessage,
+	8:  ErrKeepassxcAssocFailed,
+	9:  ErrKeepassxcKeyExchangeFailed,
+	10: ErrKeepassxcEncryptionKeyUnrecognized,
+	11: ErrKeepassxcNoSavedDatabasesFound,
+	12: ErrKeepassxcIncorrectAction,
+	13: ErrKeepassxcEmptyMessageReceived,
+	14: ErrKeepassxcNoUrlProvided,
+	15: ErrKeepassxcNoLoginsFound,
+	16: ErrKeepassxcNoGroupsFound,
+	17: ErrKeepassxcCannotCreateNewGroup,
+	18: ErrKeepassxcNoValidUuidProvided,
+	19: ErrKeepassxcAccessToAllEntriesDenied,
+}
+
+// ProtocolError represents an error returned by the keepassxc http api.
+// It works with errors.Is() for the error of its code, e.g. ErrKeepassxcDatabaseNotOpened,
+// unknown codes match ErrKeepassxcProtocol.
+type ProtocolError struct {
+	// The action of the failed request.
+	Action string
+	// The error code as returned by the api.
+	Code int
+	// The error message as returned by the api.
+	Message string
+}
+
+// Error returns the error message incl. its code.
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("keepassxc api error %d on %s: %s", e.Code, e.Action, e.Message)
+}
+
+// Unwrap returns the error of the code.
+func (e *ProtocolError) Unwrap() error {
+	if err, ok := protocolErrors[e.Code]; ok {
+		return err
+	}
+	return ErrKeepassxcProtocol
+}
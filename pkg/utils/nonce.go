@@ -0,0 +1,18 @@
+package utils
+
+import "github.com/kevinburke/nacl"
+
+// IncrementNonce returns a copy of the nonce incremented by one.
+// The nonce is treated as little endian number, like sodium_increment() does it.
+// The keepassxc http api answers each request with its incremented nonce.
+func IncrementNonce(nonce nacl.Nonce) nacl.Nonce {
+	incremented := new([nacl.NonceSize]byte)
+	copy(incremented[:], nonce[:])
+	for i := range incremented {
+		incremented[i]++
+		if incremented[i] != 0 {
+			break
+		}
+	}
+	return incremented
+}
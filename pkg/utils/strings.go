@@ -1,6 +1,9 @@
 package utils
 
-import "strings"
+import (
+	"path"
+	"strings"
+)
 
 func ContainsAll(str string, substr ...string) bool {
 	for _, s := range substr {
@@ -10,3 +13,42 @@ func ContainsAll(str string, substr ...string) bool {
 	}
 	return true
 }
+
+// MatchGroupPath reports whether the full group path (e.g. "/scripts/clip") matches the pattern.
+// The pattern is matched segment by segment with path.Match() syntax,
+// additionally the segment "**" matches any number of segments (incl. none).
+// Examples: "/scripts/**" matches "/scripts" and everything below it, "/work/*/prod" matches "/work/a/prod".
+func MatchGroupPath(pattern, groupPath string) bool {
+	return matchSegments(splitGroupPath(pattern), splitGroupPath(groupPath))
+}
+
+// splitGroupPath splits a group path into its segments, the root group "/" has none.
+func splitGroupPath(groupPath string) []string {
+	groupPath = strings.Trim(groupPath, "/")
+	if groupPath == "" {
+		return nil
+	}
+	return strings.Split(groupPath, "/")
+}
+
+// matchSegments is the recursive implementation of MatchGroupPath.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,32 @@
+package utils
+
+import "testing"
+
+func TestMatchGroupPath(t *testing.T) {
+	for _, test := range []struct {
+		pattern, groupPath string
+		match              bool
+	}{
+		{"/scripts/**", "/scripts", true},
+		{"/scripts/**", "/scripts/clip", true},
+		{"/scripts/**", "/scripts/clip/deep", true},
+		{"/scripts/**", "/other/scripts", false},
+		{"/scripts/**", "/", false},
+		{"/work/*/prod", "/work/a/prod", true},
+		{"/work/*/prod", "/work/prod", false},
+		{"/work/*/prod", "/work/a/b/prod", false},
+		{"/work/*/prod", "/work/a/prod/sub", false},
+		{"/work/**/prod", "/work/prod", true},
+		{"/work/**/prod", "/work/a/b/prod", true},
+		{"/scripts/clip", "/scripts/clip", true},
+		{"/scripts/clip/", "/scripts/clip", true},
+		{"/scripts/clip", "/scripts/clip2", false},
+		{"/**", "/", true},
+		{"/", "/", true},
+		{"/[", "/[", false},
+	} {
+		if match := MatchGroupPath(test.pattern, test.groupPath); match != test.match {
+			t.Errorf("MatchGroupPath(%q, %q) = %t, want %t", test.pattern, test.groupPath, match, test.match)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/kevinburke/nacl"
@@ -45,6 +47,68 @@ func (p ViperKeepassxcProfile) GetAssocKey() nacl.Key {
 func (p ViperKeepassxcProfile) SetAssoc(name string, key nacl.Key) error {
 	viper.Set(ConfigKeypathAssocName, name)
 	viper.Set(ConfigKeypathAssocKey, NaclKeyToB64(key))
-	fmt.Printf("Save config to %s\n", viper.ConfigFileUsed())
-	return viper.WriteConfig()
+	return writeConfig()
+}
+
+func (p ViperKeepassxcProfile) GetDatabaseHashes() []string {
+	assocs := viper.GetStringMap(ConfigKeypathAssocs)
+	hashes := make([]string, 0, len(assocs))
+	for hash := range assocs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func (p ViperKeepassxcProfile) GetDatabaseAssoc(hash string) (string, nacl.Key) {
+	b64String := viper.GetString(databaseAssocKeypath(hash, ConfigKeySuffixAssocKey))
+	if b64String == "" {
+		return "", nil
+	}
+	return viper.GetString(databaseAssocKeypath(hash, ConfigKeySuffixAssocName)), B64ToNaclKey(b64String)
+}
+
+func (p ViperKeepassxcProfile) SetDatabaseAssoc(hash, name string, key nacl.Key) error {
+	viper.Set(databaseAssocKeypath(hash, ConfigKeySuffixAssocName), name)
+	viper.Set(databaseAssocKeypath(hash, ConfigKeySuffixAssocKey), NaclKeyToB64(key))
+	return writeConfig()
+}
+
+func (p ViperKeepassxcProfile) GetPinnedDatabaseHashes() []string {
+	return viper.GetStringSlice(ConfigKeypathPinnedDatabases)
+}
+
+func (p ViperKeepassxcProfile) SetPinnedDatabaseHashes(hashes []string) error {
+	viper.Set(ConfigKeypathPinnedDatabases, hashes)
+	return writeConfig()
+}
+
+// databaseAssocKeypath returns the config key path of a value of the association for the database hash.
+func databaseAssocKeypath(hash, suffix string) string {
+	return ConfigKeypathAssocs + "." + hash + "." + suffix
+}
+
+func (p ViperKeepassxcProfile) GetClientIdentity() (string, nacl.Key) {
+	b64String := viper.GetString(ConfigKeypathClientKey)
+	if b64String == "" {
+		return "", nil
+	}
+	return viper.GetString(ConfigKeypathClientId), B64ToNaclKey(b64String)
+}
+
+func (p ViperKeepassxcProfile) SetClientIdentity(id string, key nacl.Key) error {
+	viper.Set(ConfigKeypathClientId, id)
+	viper.Set(ConfigKeypathClientKey, NaclKeyToB64(key))
+	return writeConfig()
+}
+
+// writeConfig saves the config file, which is only readable by the user, since it contains keys.
+func writeConfig() error {
+	fmt.Fprintf(os.Stderr, "Save config to %s\n", viper.ConfigFileUsed())
+	viper.SetConfigPermissions(0o600)
+	if err := viper.WriteConfig(); err != nil {
+		return err
+	}
+	// the permissions are only applied to new files
+	return os.Chmod(viper.ConfigFileUsed(), 0o600)
 }